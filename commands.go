@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/chat"
+	"llm_term/pkg/config"
+	"llm_term/pkg/mcp"
+	"llm_term/pkg/session"
+	"llm_term/pkg/ui"
+)
+
+const usage = `usage: llm_term [flags] [command]
+
+Without a command, starts a new chat.
+
+Flags:
+  -config <file>     config file (default ~/.config/llm_term/config.toml)
+  -profile <name>    profile from the config file
+  -endpoint <url>    chat endpoint, overriding the profile and LLM_ENDPOINT
+  -provider <name>   API format, ollama or openai
+  -model <name>      model, overriding the profile and LLM_MODEL
+
+Commands:
+  import <file>...   import conversations from ChatGPT, Open WebUI or OpenAI messages JSON
+  sessions           list saved sessions
+  resume <id>        continue a saved session
+  search [-regex] <query>
+                     search messages across all saved sessions
+  mcp                start the configured MCP servers and list what they offer
+  pull [model]       download a model to the Ollama server, the configured model by default
+`
+
+func runCommand(name string, args []string, flags config.Flags) error {
+	switch name {
+	case "import":
+		return importCommand(args)
+	case "sessions":
+		return sessionsCommand()
+	case "resume":
+		return resumeCommand(args, flags)
+	case "search":
+		return searchCommand(args)
+	case "mcp":
+		return mcpCommand()
+	case "pull":
+		return pullCommand(args, flags)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func importCommand(files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("import: no files given")
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+
+	// Conversations imported before are skipped rather than duplicated
+	imported, err := store.Imported()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sessions, err := session.Import(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		saved := 0
+		for _, sess := range sessions {
+			key := sess.Source + "/" + sess.SourceID
+			if imported[key] {
+				continue
+			}
+			if err := store.Save(sess); err != nil {
+				return err
+			}
+			imported[key] = true
+			saved++
+			fmt.Printf("%s  %s (%d messages)\n", sess.ID, sess.Title, len(sess.Messages))
+		}
+		fmt.Printf("Imported %d conversations from %s", saved, file)
+		if skipped := len(sessions) - saved; skipped > 0 {
+			fmt.Printf(", skipped %d imported before", skipped)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func sessionsCommand() error {
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, sess := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", sess.ID, sess.Updated.Format("2006-01-02 15:04"), sess.Model, sess.Title)
+	}
+	return w.Flush()
+}
+
+func resumeCommand(args []string, flags config.Flags) error {
+	if len(args) != 1 {
+		return fmt.Errorf("resume: expected a session id")
+	}
+	cfg, err := config.Load(flags)
+	if err != nil {
+		return err
+	}
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	sess, err := store.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	app, err := ui.New(cfg)
+	if err != nil {
+		return err
+	}
+	app.LoadSession(sess)
+	return app.Run()
+}
+
+func searchCommand(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	regex := flags.Bool("regex", false, "treat the query as a regular expression")
+	limit := flags.Int("n", 20, "maximum number of results")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("search: no query given")
+	}
+
+	store, err := session.NewStore()
+	if err != nil {
+		return err
+	}
+	results, err := store.Search(strings.Join(flags.Args(), " "), session.SearchOptions{Regex: *regex, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No matches")
+		return nil
+	}
+
+	for _, r := range results {
+		fmt.Printf("%s  %s  %s (message %d)\n    %s\n",
+			r.SessionID, r.Updated.Format("2006-01-02"), r.Title, r.Message+1, r.Snippet)
+	}
+	fmt.Println("\nOpen a session with: llm_term resume <id>")
+	return nil
+}
+
+func mcpCommand() error {
+	path, err := mcp.ConfigPath()
+	if err != nil {
+		return err
+	}
+	config, err := mcp.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Servers) == 0 {
+		fmt.Printf("No MCP servers configured in %s\n", path)
+		return nil
+	}
+
+	names := make([]string, 0, len(config.Servers))
+	for name := range config.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		server := config.Servers[name]
+		if server.Disabled {
+			fmt.Printf("%s (disabled)\n\n", name)
+			continue
+		}
+		if err := describeMCPServer(name, server); err != nil {
+			fmt.Printf("%v\n\n", err)
+		}
+	}
+	return nil
+}
+
+func describeMCPServer(name string, server mcp.ServerConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mcp.Start(ctx, name, server)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	fmt.Printf("%s: %s %s\n", name, client.Server.Name, client.Server.Version)
+	if client.Supports("tools") {
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: listing tools: %w", name, err)
+		}
+		fmt.Println("  tools:")
+		for _, tool := range tools {
+			fmt.Printf("    %s  %s\n", tool.Name, firstLine(tool.Description))
+		}
+	}
+	if client.Supports("resources") {
+		resources, err := client.ListResources(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: listing resources: %w", name, err)
+		}
+		fmt.Println("  resources:")
+		for _, resource := range resources {
+			fmt.Printf("    %s  %s\n", resource.URI, resource.Name)
+		}
+	}
+	if client.Supports("prompts") {
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: listing prompts: %w", name, err)
+		}
+		fmt.Println("  prompts:")
+		for _, prompt := range prompts {
+			fmt.Printf("    %s  %s\n", prompt.Name, firstLine(prompt.Description))
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+func pullCommand(args []string, flags config.Flags) error {
+	if len(args) > 1 {
+		return fmt.Errorf("pull: expected at most one model")
+	}
+	if len(args) == 1 {
+		flags.Model = args[0]
+	}
+	cfg, err := config.Load(flags)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Layers download one after another; each gets a line that is
+	// rewritten in place while it downloads
+	var current string
+	err = chat.New(cfg).Pull(ctx, cfg.Model, func(progress chat.PullProgress) {
+		line := progress.Status
+		if progress.Total > 0 {
+			line = fmt.Sprintf("%s  %3d%%  %s / %s", progress.Status, progress.Completed*100/progress.Total,
+				attach.FormatSize(progress.Completed), attach.FormatSize(progress.Total))
+		}
+		if progress.Status != current && current != "" {
+			fmt.Println()
+		}
+		current = progress.Status
+		fmt.Printf("\r\033[K%s", line)
+	})
+	fmt.Println()
+	return err
+}
@@ -3,6 +3,7 @@ module llm_term
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gdamore/tcell/v2 v2.7.4
 	github.com/joho/godotenv v1.5.1
 	github.com/rivo/tview v0.0.0-20241227133733-17b7edb88c57
@@ -1,13 +1,42 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"llm_term/pkg/config"
+	"llm_term/pkg/redact"
 	"llm_term/pkg/ui"
 	"log"
+	"os"
 )
 
 func main() {
-	app := ui.New()
+	var flags config.Flags
+	fs := flag.NewFlagSet("llm_term", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	fs.StringVar(&flags.Path, "config", "", "")
+	fs.StringVar(&flags.Profile, "profile", "", "")
+	fs.StringVar(&flags.Endpoint, "endpoint", "", "")
+	fs.StringVar(&flags.Provider, "provider", "", "")
+	fs.StringVar(&flags.Model, "model", "", "")
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() > 0 {
+		if err := runCommand(fs.Arg(0), fs.Args()[1:], flags); err != nil {
+			log.Fatal(redact.String(err.Error()))
+		}
+		return
+	}
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		log.Fatal(redact.String(err.Error()))
+	}
+	app, err := ui.New(cfg)
+	if err != nil {
+		log.Fatal(redact.String(err.Error()))
+	}
 	if err := app.Run(); err != nil {
-		log.Fatal(err)
+		log.Fatal(redact.String(err.Error()))
 	}
-}
\ No newline at end of file
+}
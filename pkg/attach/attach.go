@@ -0,0 +1,325 @@
+package attach
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum size of a single attached file
+const MaxFileSize = 256 * 1024
+
+// Maximum size of an attached image, which is sent as-is to vision models
+const MaxImageSize = 20 * 1024 * 1024
+
+// Number of leading bytes checked when detecting binary files
+const sniffSize = 8000
+
+// Maximum number of path completions offered at once
+const maxCompletions = 20
+
+// referencePattern matches @path at the start of the prompt or after
+// whitespace, so e-mail addresses aren't treated as attachments
+var referencePattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+var (
+	ErrTooLarge = fmt.Errorf("larger than %d KB", MaxFileSize/1024)
+	ErrBinary   = errors.New("binary file")
+)
+
+// Extensions of images that are attached as images rather than text
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// Attachment is a file referenced from a prompt. Text files have Content,
+// images have base64-encoded Image data. Err says why it can't be attached,
+// in which case the reference is left as plain text.
+type Attachment struct {
+	Path    string
+	Size    int64
+	Content string
+	Image   string
+	Err     error
+}
+
+func (a Attachment) IsImage() bool {
+	return a.Image != ""
+}
+
+// Find returns the files referenced with @path in a prompt, with their contents
+func Find(text string) []Attachment {
+	paths := references(text)
+	attachments := make([]Attachment, len(paths))
+	for i, path := range paths {
+		attachments[i] = load(path)
+	}
+	return attachments
+}
+
+// Stat returns the files referenced with @path in a prompt without reading
+// them, which is cheap enough to do on every keystroke. Their size is set,
+// but not their contents, and binary files aren't detected.
+func Stat(text string) []Attachment {
+	paths := references(text)
+	attachments := make([]Attachment, len(paths))
+	for i, path := range paths {
+		attachments[i], _ = stat(path)
+	}
+	return attachments
+}
+
+// references returns the paths referenced in a prompt, each once
+func references(text string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, m := range referencePattern.FindAllStringSubmatch(text, -1) {
+		path := m[2]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func load(path string) Attachment {
+	a, isImage := stat(path)
+	if a.Err != nil {
+		return a
+	}
+
+	data, err := os.ReadFile(ExpandHome(path))
+	if err != nil {
+		a.Err = err
+		return a
+	}
+	if isImage {
+		a.Image = base64.StdEncoding.EncodeToString(data)
+		return a
+	}
+	if isBinary(data) {
+		a.Err = ErrBinary
+		return a
+	}
+	a.Content = string(data)
+	return a
+}
+
+// stat checks that a path can be attached and whether it's an image
+func stat(path string) (Attachment, bool) {
+	a := Attachment{Path: path}
+	info, err := os.Stat(ExpandHome(path))
+	if err != nil {
+		a.Err = errors.New("not found")
+		return a, false
+	}
+	if info.IsDir() {
+		a.Err = errors.New("is a directory")
+		return a, false
+	}
+	a.Size = info.Size()
+	isImage := imageExtensions[strings.ToLower(filepath.Ext(path))]
+	if isImage && a.Size > MaxImageSize {
+		a.Err = fmt.Errorf("larger than %d MB", MaxImageSize/(1024*1024))
+	} else if !isImage && a.Size > MaxFileSize {
+		a.Err = ErrTooLarge
+	}
+	return a, isImage
+}
+
+// isBinary treats files with NUL bytes or invalid UTF-8 near the start as binary
+func isBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > sniffSize {
+		sniff = sniff[:sniffSize]
+		// Don't reject a file because the cut split a multi-byte rune
+		for i := 0; i < utf8.UTFMax && len(sniff) > 0 && !utf8.Valid(sniff); i++ {
+			sniff = sniff[:len(sniff)-1]
+		}
+	}
+	return bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(sniff)
+}
+
+// ExpandHome replaces a leading ~/ with the home directory
+func ExpandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// Expand appends the contents of every attachable text file to the prompt as
+// a fenced block whose info string names the path. Images are left for the
+// caller to send with Images. References that can't be attached stay as they are.
+func Expand(text string) (string, []Attachment) {
+	attachments := Find(text)
+
+	var b strings.Builder
+	b.WriteString(text)
+	for _, a := range attachments {
+		if a.Err != nil || a.IsImage() {
+			continue
+		}
+		fence := fenceFor(a.Content)
+		fmt.Fprintf(&b, "\n\n%s%s\n%s", fence, a.Path, a.Content)
+		if !strings.HasSuffix(a.Content, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(fence)
+	}
+	return b.String(), attachments
+}
+
+// Collapse undoes Expand for a stored prompt, returning the text as it was
+// typed and the text files appended to it, with their sizes. It's how a
+// resumed session shows chips instead of the file contents.
+func Collapse(content string) (string, []Attachment) {
+	for i := strings.Index(content, "\n\n`"); i >= 0; {
+		text := content[:i]
+		if attachments, ok := appendedFiles(content[i:], references(text)); ok {
+			return text, attachments
+		}
+		next := strings.Index(content[i+1:], "\n\n`")
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	return content, nil
+}
+
+// appendedFiles parses the fenced blocks Expand appends, which must make up
+// all of blocks and each name one of paths
+func appendedFiles(blocks string, paths []string) ([]Attachment, bool) {
+	referenced := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		referenced[path] = true
+	}
+	var attachments []Attachment
+	for blocks != "" {
+		if !strings.HasPrefix(blocks, "\n\n```") {
+			return nil, false
+		}
+		blocks = blocks[2:]
+		fence := blocks[:len(blocks)-len(strings.TrimLeft(blocks, "`"))]
+		path, rest, ok := strings.Cut(blocks[len(fence):], "\n")
+		if !ok || !referenced[path] {
+			return nil, false
+		}
+		// The fence is longer than any run of backticks in the file, so the
+		// first one on a line of its own closes the block
+		end := strings.Index("\n"+rest, "\n"+fence)
+		if end < 0 {
+			return nil, false
+		}
+		// The size includes the newline Expand adds to a file without one
+		attachments = append(attachments, Attachment{Path: path, Size: int64(end)})
+		blocks = rest[end+len(fence):]
+	}
+	return attachments, len(attachments) > 0
+}
+
+// Images returns the base64 data of the attached images
+func Images(attachments []Attachment) []string {
+	var images []string
+	for _, a := range attachments {
+		if a.IsImage() {
+			images = append(images, a.Image)
+		}
+	}
+	return images
+}
+
+// fenceFor picks a backtick fence longer than any run inside the content
+func fenceFor(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// FormatSize renders a byte count for attachment previews and downloads
+func FormatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	case size < 1024*1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+	return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+}
+
+// Complete returns completions for an @path reference being typed at the
+// end of text. Each completion is the full text with the path completed;
+// directories end in a slash so completion can continue into them.
+func Complete(text string) []string {
+	start := strings.LastIndexAny(text, " \t\n") + 1
+	word := text[start:]
+	if !strings.HasPrefix(word, "@") {
+		return nil
+	}
+	typed := word[1:]
+
+	dir, prefix := filepath.Split(typed)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(ExpandHome(readDir))
+	if err != nil {
+		return nil
+	}
+
+	var completions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// Hidden files only when asked for
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		completions = append(completions, text[:start]+"@"+dir+name)
+	}
+	sort.Strings(completions)
+	if len(completions) > maxCompletions {
+		completions = completions[:maxCompletions]
+	}
+	// Nothing to offer if the only completion is what's already typed
+	if len(completions) == 1 && completions[0] == text {
+		return nil
+	}
+	return completions
+}
@@ -0,0 +1,60 @@
+package attach
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollapse(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	code := write("main.go", "package main\n")
+	fenced := write("notes.md", "```go\nx := 1\n```\n\n\n")
+	empty := write("empty.txt", "")
+
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{"no attachments", "just a question", "[]"},
+		{"one file", "explain @" + code, fmt.Sprintf("[%s:13]", code)},
+		{"file with fences", "@" + fenced + " and @" + code + " please", fmt.Sprintf("[%s:19 %s:13]", fenced, code)},
+		{"empty file", "@" + empty, fmt.Sprintf("[%s:1]", empty)},
+		{"missing file", "@" + filepath.Join(dir, "gone.txt"), "[]"},
+	}
+	for _, test := range tests {
+		expanded, _ := Expand(test.prompt)
+		text, attachments := Collapse(expanded)
+		if text != test.prompt {
+			t.Errorf("%s: text = %q, want %q", test.name, text, test.prompt)
+		}
+		var got []string
+		for _, a := range attachments {
+			got = append(got, fmt.Sprintf("%s:%d", a.Path, a.Size))
+		}
+		if fmt.Sprint(got) != test.want {
+			t.Errorf("%s: attachments = %v, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCollapseLeavesTypedFences(t *testing.T) {
+	// A fence that doesn't name a referenced file was typed by the user
+	prompt := "what does this do?\n\n```path/to/file\nrm -rf /\n```"
+	if text, attachments := Collapse(prompt); text != prompt || attachments != nil {
+		t.Errorf("Collapse changed a typed prompt: %q, %v", text, attachments)
+	}
+	prompt = "see @file.txt\n\n```file.txt\ntyped\n```\ntrailing"
+	if text, attachments := Collapse(prompt); text != prompt || attachments != nil {
+		t.Errorf("Collapse took a block with text after it: %q, %v", text, attachments)
+	}
+}
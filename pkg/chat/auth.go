@@ -0,0 +1,196 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/redact"
+)
+
+// How long an API key command like `pass show openai` may take, which
+// includes waiting for a passphrase prompt
+const keyCommandTimeout = 30 * time.Second
+
+// credential is what to send to one backend. Entries in credentials.json
+// are keyed by host (e.g. "api.openai.com") or by provider ("openai" or
+// "ollama"), the host taking precedence.
+type credential struct {
+	APIKey string `json:"api_key"`
+	// APIKeyCommand is run with sh -c and its output used as the key
+	APIKeyCommand string            `json:"api_key_command"`
+	Headers       map[string]string `json:"headers"`
+	// err is why the credentials couldn't be read, reported when they're used
+	err error
+}
+
+var (
+	credentialsOnce sync.Once
+	credentials     map[string]credential
+	credentialsErr  error
+
+	// Key commands run once per process
+	keyCommandMu sync.Mutex
+	keyCommands  = make(map[string]string)
+)
+
+// CredentialsPath returns LLM_CREDENTIALS if set, otherwise credentials.json
+// in the XDG config directory (~/.config/llm_term/credentials.json by default)
+func CredentialsPath() (string, error) {
+	if path := os.Getenv("LLM_CREDENTIALS"); path != "" {
+		return path, nil
+	}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "llm_term", "credentials.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating credentials: %w", err)
+	}
+	return filepath.Join(home, ".config", "llm_term", "credentials.json"), nil
+}
+
+// loadCredentials reads credentials.json once. A missing file has no entries.
+func loadCredentials() (map[string]credential, error) {
+	credentialsOnce.Do(func() {
+		path, err := CredentialsPath()
+		if err != nil {
+			credentialsErr = err
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				credentialsErr = err
+			}
+			return
+		}
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			credentialsErr = fmt.Errorf("%s: %w", path, err)
+		}
+	})
+	return credentials, credentialsErr
+}
+
+// credentialFor collects the key and headers for an endpoint. The config's
+// key and headers apply to the configured endpoint's host, then come
+// credentials.json entries for the host and the provider, and finally
+// OPENAI_API_KEY for api.openai.com. The first key found is used, and
+// earlier headers win over later ones.
+func credentialFor(endpoint string, p provider, cfg *config.Config) credential {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return credential{err: err}
+	}
+	var found []credential
+
+	if primary, err := url.Parse(cfg.Endpoint); err == nil && primary.Host == u.Host {
+		found = append(found, credential{APIKey: cfg.APIKey, APIKeyCommand: cfg.APIKeyCommand, Headers: cfg.Headers})
+	}
+
+	entries, err := loadCredentials()
+	if err != nil {
+		return credential{err: err}
+	}
+	for _, name := range []string{u.Host, u.Hostname(), p.name()} {
+		if entry, ok := entries[name]; ok {
+			found = append(found, entry)
+		}
+	}
+
+	// Only OpenAI itself gets OPENAI_API_KEY, not every compatible server
+	if u.Hostname() == "api.openai.com" {
+		found = append(found, credential{APIKey: os.Getenv("OPENAI_API_KEY")})
+	}
+
+	merged := credential{Headers: make(map[string]string)}
+	for _, c := range found {
+		if merged.APIKey == "" && merged.APIKeyCommand == "" {
+			merged.APIKey, merged.APIKeyCommand = c.APIKey, c.APIKeyCommand
+		}
+		for name, value := range c.Headers {
+			if _, set := merged.Headers[http.CanonicalHeaderKey(name)]; !set {
+				merged.Headers[http.CanonicalHeaderKey(name)] = value
+			}
+		}
+	}
+	return merged
+}
+
+// authorize adds an endpoint's API key and extra headers to a request. The
+// key is sent as a bearer token unless the headers set Authorization.
+func authorize(request *http.Request, cred credential) error {
+	if cred.err != nil {
+		return cred.err
+	}
+
+	key := cred.APIKey
+	var err error
+	if key == "" && cred.APIKeyCommand != "" {
+		if key, err = runKeyCommand(request.Context(), cred.APIKeyCommand); err != nil {
+			return err
+		}
+	}
+	if key != "" {
+		redact.Add(key)
+		request.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	for name, value := range cred.Headers {
+		if secretHeader(name) {
+			redact.Add(strings.TrimPrefix(value, "Bearer "))
+		}
+		request.Header.Set(name, value)
+	}
+	return nil
+}
+
+// secretHeader guesses whether a header carries a credential, so its value
+// is redacted while ordinary headers like an organization name aren't
+func secretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range []string{"authorization", "key", "token", "secret"} {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// runKeyCommand runs a command that prints an API key, such as a password
+// manager lookup
+func runKeyCommand(ctx context.Context, command string) (string, error) {
+	keyCommandMu.Lock()
+	defer keyCommandMu.Unlock()
+	if key, ok := keyCommands[command]; ok {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, keyCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("API key command %q: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	// Password managers may print more lines after the secret
+	key, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("API key command %q printed nothing", command)
+	}
+	redact.Add(key)
+	keyCommands[command] = key
+	return key, nil
+}
@@ -1,17 +1,18 @@
 package chat
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
+	"strings"
 	"sync"
 
+	"llm_term/pkg/config"
+	"llm_term/pkg/redact"
 	"llm_term/pkg/types"
 
-	"github.com/joho/godotenv"
 	"github.com/rivo/tview"
 )
 
@@ -23,57 +24,130 @@ type Chat struct {
 	cancelChan chan struct{}
 	mu sync.Mutex
 	isStreaming bool
+	// lastReply is the answer from the most recent StreamChat call, if it completed
+	lastReply *types.Message
+	cancelCtx context.CancelFunc
+	tools map[string]Tool
+	hooks Hooks
+	// jsonMode applies to the next turn only
+	jsonMode *JSONMode
+	// turnContext is sent with the next turn only, and not kept in history
+	turnContext string
+	config   *config.Config
 }
 
-func New() *Chat {
+func New(cfg *config.Config) *Chat {
 	return &Chat{
 		history: make([]types.Message, 0),
 		cancelChan: make(chan struct{}),
+		config: cfg,
 	}
 }
 
+// SetConfig switches the settings used from the next request on
+func (c *Chat) SetConfig(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = cfg
+}
+
+// SetTurnContext makes the next StreamChat call send text to the model as
+// a system message, such as excerpts retrieved for the prompt. It applies
+// to that turn only and isn't added to the history.
+func (c *Chat) SetTurnContext(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.turnContext = text
+}
+
+func (c *Chat) Config() *config.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config
+}
+
 func (c *Chat) Cancel() {
 	c.mu.Lock()
 	if c.isStreaming {
 		close(c.cancelChan)
+		c.cancelCtx()
 		c.isStreaming = false
 	}
 	c.mu.Unlock()
 }
 
 func (c *Chat) addToHistory(message types.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.history = append(c.history, message)
 	
-	// Trim history if it exceeds max size
+	// Trim history if it exceeds max size, cutting at a user message so
+	// tool results aren't kept without the call that asked for them
 	if len(c.history) > maxHistorySize {
-		c.history = c.history[len(c.history)-maxHistorySize:]
+		c.history = c.history[trimPoint(c.history, len(c.history)-maxHistorySize):]
 	}
 }
 
-func init() {
-	// Load .env file if it exists
-	godotenv.Load()
+// trimPoint is the first user message at or after from, or the last one
+// before it when the whole window is a single turn of tool calls
+func trimPoint(history []types.Message, from int) int {
+	for i := from; i < len(history); i++ {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+	for i := from - 1; i > 0; i-- {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+	return 0
 }
 
-func getConfig() (endpoint string, model string, err error) {
-	endpoint = os.Getenv("LLM_ENDPOINT")
-	if endpoint == "" {
-		return "", "", fmt.Errorf("LLM_ENDPOINT environment variable is not set")
-	}
-	
-	model = os.Getenv("LLM_MODEL")
-	if model == "" {
-		return "", "", fmt.Errorf("LLM_MODEL environment variable is not set")
+// History returns a copy of the conversation so far
+func (c *Chat) History() []types.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	history := make([]types.Message, len(c.history))
+	copy(history, c.history)
+	return history
+}
+
+// LastReply returns the assistant message produced by the most recent
+// StreamChat call. It reports false if that call failed or was cancelled.
+func (c *Chat) LastReply() (types.Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastReply == nil {
+		return types.Message{}, false
 	}
-	
-	return endpoint, model, nil
+	return *c.lastReply, true
+}
+
+// SetHistory replaces the conversation, e.g. when resuming a saved session
+func (c *Chat) SetHistory(messages []types.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = make([]types.Message, len(messages))
+	copy(c.history, messages)
 }
 
-func (c *Chat) StreamChat(text string, chatView *tview.TextView, app *tview.Application, onResponse func(types.ChatResponse), onComplete func()) {
+func (c *Chat) StreamChat(userMessage types.Message, chatView *tview.TextView, app *tview.Application, onResponse func(types.ChatResponse), onComplete func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Create new cancel channel for this stream
 	c.mu.Lock()
 	c.cancelChan = make(chan struct{})
+	c.cancelCtx = cancel
 	c.isStreaming = true
+	c.lastReply = nil
+	jsonMode := c.jsonMode
+	c.jsonMode = nil
+	turnContext := c.turnContext
+	c.turnContext = ""
+	// The whole turn uses the settings it started with
+	cfg := c.config
 	c.mu.Unlock()
 
 	// Ensure we mark streaming as done when we exit
@@ -86,83 +160,237 @@ func (c *Chat) StreamChat(text string, chatView *tview.TextView, app *tview.Appl
 		}
 	}()
 
-	endpoint, model, err := getConfig()
-	if err != nil {
-		fmt.Fprintf(chatView, "[red]Configuration Error: %v\n[yellow]Please set the required environment variables in your .env file.[white]\n", err)
-		return
-	}
+	targets := targets(cfg)
+	// Later rounds of the turn stay with the endpoint that answered
+	current := 0
 
-	userMessage := types.Message{
-		Role:    "user",
-		Content: text,
+	userMessage.Role = "user"
+	var format json.RawMessage
+	if jsonMode != nil {
+		format = jsonMode.format()
 	}
 	c.addToHistory(userMessage)
-	
-	request := types.ChatRequest{
-		Model:       model,
-		Temperature: 1,
-		Messages:    c.history,
+
+	// Keep asking while the model calls tools, feeding back their results,
+	// or while a JSON answer needs fixing
+	var assistantMessage types.Message
+	retries := 0
+	for round := 0; ; round++ {
+		request := types.ChatRequest{
+			Temperature: 1,
+			Messages:    c.requestHistory(cfg, turnContext),
+			Options:     cfg.Options,
+			Tools:       c.toolDefinitions(),
+			Format:      format,
+		}
+		if jsonMode != nil {
+			jsonMode.instruct(request.Messages, userMessage)
+		}
+
+		// JSON is shown once it's complete and indented rather than as it streams
+		var ok bool
+		assistantMessage, ok = c.streamResponse(ctx, cfg, targets, &current, request, round == 0, jsonMode == nil, chatView, app, onResponse)
+		if !ok {
+			return
+		}
+		c.addToHistory(assistantMessage)
+
+		if len(assistantMessage.ToolCalls) > 0 {
+			if round+1 >= maxToolRounds {
+				app.QueueUpdateDraw(func() {
+					fmt.Fprintf(chatView, "\n[warning]Stopped after %d rounds of tool calls[-]", maxToolRounds)
+				})
+				break
+			}
+			c.runToolCalls(ctx, assistantMessage.ToolCalls)
+			continue
+		}
+		if jsonMode == nil {
+			break
+		}
+
+		pretty, problems := jsonMode.check(assistantMessage.Content)
+		if len(problems) == 0 {
+			app.QueueUpdateDraw(func() {
+				fmt.Fprintf(chatView, "\n%s", tview.Escape(pretty))
+			})
+			break
+		}
+		if retries >= jsonMode.Retries {
+			app.QueueUpdateDraw(func() {
+				fmt.Fprintf(chatView, "\n%s\n[error]Invalid JSON after %d retries:\n  %s[-]",
+					tview.Escape(assistantMessage.Content), retries, tview.Escape(strings.Join(problems, "\n  ")))
+			})
+			break
+		}
+		retries++
+		app.QueueUpdateDraw(func() {
+			fmt.Fprintf(chatView, "\n[warning]Answer didn't match the schema (%s), asking again (%d/%d)[-]",
+				tview.Escape(problems[0]), retries, jsonMode.Retries)
+		})
+		c.addToHistory(types.Message{Role: "user", Content: jsonMode.correction(problems)})
 	}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		fmt.Fprintf(chatView, "[red]Error: %v\n", err)
+	c.mu.Lock()
+	c.lastReply = &assistantMessage
+	c.mu.Unlock()
+	
+	app.QueueUpdateDraw(func() {
+		fmt.Fprintf(chatView, "\n")
+	})
+}
+
+// writeError shows a failed request, with a suggested fix when the backend
+// said what went wrong. It writes to the chat view, so it runs on the UI
+// goroutine.
+func writeError(chatView *tview.TextView, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		fmt.Fprintf(chatView, "[error]Error: %v[-]\n", tview.Escape(redact.String(err.Error())))
 		return
 	}
+	fmt.Fprintf(chatView, "[error]%s:[-] %s\n", apiErr.Kind, tview.Escape(apiErr.Message))
+	if hint := apiErr.Hint(); hint != "" {
+		fmt.Fprintf(chatView, "[warning]%s[-]\n", tview.Escape(hint))
+	}
+}
+
+// streamResponse sends one request and streams the answer into the chat
+// view. It reports false if the request failed or was cancelled.
+func (c *Chat) streamResponse(ctx context.Context, cfg *config.Config, targets []target, current *int, request types.ChatRequest, showLabel, showContent bool, chatView *tview.TextView, app *tview.Application, onResponse func(types.ChatResponse)) (types.Message, bool) {
+	assistantMessage := types.Message{Role: "assistant"}
+
+	c.mu.Lock()
+	hooks := c.hooks
+	c.mu.Unlock()
 
-	resp, err := http.Post(endpoint, "application/json", bytes.NewBuffer(jsonData))
+	previous := *current
+	notify := func(notice string) {
+		app.QueueUpdateDraw(func() {
+			fmt.Fprintf(chatView, "[warning]%s[-]\n", tview.Escape(notice))
+		})
+	}
+	resp, err := c.connect(ctx, cfg, targets, current, request, notify)
+	// Only the main endpoint is pulled to, since fallbacks are used while
+	// it's failing
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Kind == ErrModelNotFound && apiErr.Ollama &&
+		apiErr.Endpoint == targets[0].host() && hooks.OnModelMissing != nil && hooks.OnModelMissing(ctx, apiErr.Model) {
+		resp, err = c.connect(ctx, cfg, targets, current, request, notify)
+	}
 	if err != nil {
-		fmt.Fprintf(chatView, "[red]Error: %v\n", err)
-		return
+		if ctx.Err() != nil {
+			app.QueueUpdateDraw(func() {
+				fmt.Fprintf(chatView, "\n[warning]Response cancelled by user[-]\n")
+			})
+			return assistantMessage, false
+		}
+		app.QueueUpdateDraw(func() {
+			writeError(chatView, err)
+		})
+		return assistantMessage, false
 	}
 	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(resp.Body)
-	app.QueueUpdateDraw(func() {
-		fmt.Fprintf(chatView, "[green]AI:[white] ")
-	})
-	
-	var assistantMessage types.Message
-	assistantMessage.Role = "assistant"
+	answered := targets[*current]
+	if *current != previous {
+		app.QueueUpdateDraw(func() {
+			fmt.Fprintf(chatView, "[muted]Answered by %s (%s)[-]\n", tview.Escape(answered.host()), tview.Escape(answered.model))
+		})
+	}
+
+	stream := answered.provider.newStream(resp.Body)
+	if showLabel {
+		app.QueueUpdateDraw(func() {
+			fmt.Fprintf(chatView, "[assistant]AI:[-] ")
+		})
+	}
+
+	// Reasoning arrives either in its own field or as <think> tags at the
+	// start of the content, and goes to the hooks instead of the chat view
+	var splitter thinkSplitter
+	thinking := false
+	endThinking := func() {
+		if thinking {
+			thinking = false
+			if hooks.OnThinkingDone != nil {
+				hooks.OnThinkingDone()
+			}
+		}
+	}
+	emit := func(thought, answer string) {
+		if thought != "" {
+			thinking = true
+			assistantMessage.Thinking += thought
+			if hooks.OnThinking != nil {
+				hooks.OnThinking(thought)
+			}
+		}
+		if answer != "" {
+			endThinking()
+			assistantMessage.Content += answer
+			if showContent {
+				app.QueueUpdateDraw(func() {
+					fmt.Fprintf(chatView, "%s", answer)
+				})
+			}
+		}
+	}
+	finish := func() {
+		emit(splitter.flush())
+		endThinking()
+	}
 	
-	streamLoop:
 	for {
 		select {
 		case <-c.cancelChan:
+			endThinking()
 			app.QueueUpdateDraw(func() {
-				fmt.Fprintf(chatView, "\n[yellow]Response cancelled by user[white]\n")
+				fmt.Fprintf(chatView, "\n[warning]Response cancelled by user[-]\n")
 			})
-			return
+			return assistantMessage, false
 		default:
-			var response types.ChatResponse
-			if err := decoder.Decode(&response); err != nil {
+			response, err := stream.Next()
+			if err != nil {
 				if err == io.EOF {
-					break streamLoop
+					finish()
+					return assistantMessage, true
+				}
+				if ctx.Err() != nil {
+					continue // Report the cancellation above
 				}
-				fmt.Fprintf(chatView, "[red]Error: %v\n", err)
-				return
+				endThinking()
+				if errors.As(err, &apiErr) && apiErr.Endpoint == "" {
+					apiErr.Endpoint, apiErr.Model = answered.host(), answered.model
+					_, apiErr.Ollama = answered.provider.(ollamaProvider)
+				}
+				app.QueueUpdateDraw(func() {
+					writeError(chatView, err)
+				})
+				return assistantMessage, false
 			}
 
-			app.QueueUpdateDraw(func() {
-				fmt.Fprintf(chatView, "%s", response.Message.Content)
-			})
-
-			assistantMessage.Content += response.Message.Content
+			thought, answer := splitter.push(response.Message.Content)
+			emit(response.Message.Thinking+thought, answer)
+			for _, call := range response.Message.ToolCalls {
+				// Both providers need an id to match results to calls
+				if call.ID == "" {
+					c.mu.Lock()
+					n := len(c.history)
+					c.mu.Unlock()
+					call.ID = fmt.Sprintf("call_%d", n+len(assistantMessage.ToolCalls))
+				}
+				assistantMessage.ToolCalls = append(assistantMessage.ToolCalls, call)
+			}
 			
 			if onResponse != nil {
 				onResponse(response)
 			}
 
 			if response.Done {
-				break streamLoop
+				finish()
+				return assistantMessage, true
 			}
 		}
 	}
-	
-	// Add the complete assistant message to history
-	c.addToHistory(assistantMessage)
-	
-	app.QueueUpdateDraw(func() {
-		fmt.Fprintf(chatView, "\n")
-	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EmbedModel returns the configured embedding model, or the provider's usual
+// one
+func (c *Chat) EmbedModel() string {
+	cfg := c.Config()
+	if cfg.EmbedModel != "" {
+		return cfg.EmbedModel
+	}
+	return providerFor(cfg.Provider, cfg.Endpoint).defaultEmbedModel()
+}
+
+// Embed returns one vector per input from the backend's embeddings endpoint,
+// which is the configured embed_endpoint or derived from the chat endpoint
+func (c *Chat) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	cfg := c.Config()
+	endpoint := cfg.Endpoint
+	provider := providerFor(cfg.Provider, endpoint)
+	url := cfg.EmbedEndpoint
+	if url == "" {
+		url = provider.embeddingsURL(endpoint)
+	}
+
+	body, err := provider.encodeEmbedRequest(model, inputs)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if err := authorize(request, credentialFor(url, provider, cfg)); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embeddings request failed: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	embeddings, err := provider.decodeEmbedResponse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding embeddings: %w", err)
+	}
+	if len(embeddings) != len(inputs) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(embeddings), len(inputs))
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,176 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"llm_term/pkg/redact"
+)
+
+// ErrorKind classifies a failed request so the UI can suggest a fix
+type ErrorKind int
+
+const (
+	ErrUnknown ErrorKind = iota
+	ErrModelNotFound
+	ErrAuth
+	ErrRateLimited
+	ErrServer
+	ErrContextTooLong
+	// ErrEndpointNotFound means the URL itself is wrong
+	ErrEndpointNotFound
+	ErrBadRequest
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrModelNotFound:
+		return "Model not found"
+	case ErrAuth:
+		return "Authentication failed"
+	case ErrRateLimited:
+		return "Rate limited"
+	case ErrServer:
+		return "Server error"
+	case ErrContextTooLong:
+		return "Conversation too long"
+	case ErrEndpointNotFound:
+		return "Endpoint not found"
+	case ErrBadRequest:
+		return "Request rejected"
+	}
+	return "Request failed"
+}
+
+// APIError is an error reported by the backend, either as a non-200
+// response or inside the stream
+type APIError struct {
+	Kind   ErrorKind
+	Status int
+	// Message is the backend's explanation, or the HTTP status without one
+	Message  string
+	Endpoint string
+	Model    string
+	// Ollama is set when the backend speaks Ollama's API, which decides
+	// how a missing model can be fixed
+	Ollama     bool
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// Retryable reports whether the same request might succeed later
+func (e *APIError) Retryable() bool {
+	return e.Kind == ErrRateLimited || e.Kind == ErrServer
+}
+
+// Hint suggests what the user can do about the error
+func (e *APIError) Hint() string {
+	switch e.Kind {
+	case ErrModelNotFound:
+		if e.Ollama {
+			return fmt.Sprintf("Download it with /pull %s, or choose a model you have in config.toml or LLM_MODEL.", e.Model)
+		}
+		return fmt.Sprintf("Check that %s offers %q, or choose a model it has in config.toml or LLM_MODEL.", e.Endpoint, e.Model)
+	case ErrAuth:
+		return fmt.Sprintf("%s needs valid credentials. Set api_key in config.toml or LLM_API_KEY, or add a key for it to credentials.json.", e.Endpoint)
+	case ErrRateLimited:
+		return "Wait a moment before sending again, or configure fallbacks (LLM_FALLBACKS) to fail over to another endpoint."
+	case ErrServer:
+		return "The server failed to answer. Check its logs, or configure fallbacks (LLM_FALLBACKS) to fail over to another endpoint."
+	case ErrContextTooLong:
+		return "The conversation no longer fits the model's context window. Start a new chat, or send smaller attachments."
+	case ErrEndpointNotFound:
+		return fmt.Sprintf("Nothing answers at %s. Check the endpoint in config.toml or LLM_ENDPOINT, e.g. http://localhost:11434/api/chat for Ollama.", e.Endpoint)
+	}
+	return ""
+}
+
+// newAPIError classifies an error response from its status and body.
+// Ollama sends {"error": "..."} and OpenAI-compatible servers send
+// {"error": {"message": "...", "code": "..."}}.
+func newAPIError(status int, body []byte, t target) *APIError {
+	message, code := parseErrorBody(body)
+	if message == "" {
+		message = http.StatusText(status)
+		if message == "" {
+			message = fmt.Sprintf("HTTP %d", status)
+		}
+	}
+	_, ollama := t.provider.(ollamaProvider)
+	err := &APIError{
+		Status: status,
+		// Some servers quote the rejected key back
+		Message:  redact.String(message),
+		Endpoint: t.host(),
+		Model:    t.model,
+		Ollama:   ollama,
+	}
+	err.Kind = classify(status, code, message)
+	return err
+}
+
+func parseErrorBody(body []byte) (message, code string) {
+	var envelope struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(body, &envelope) != nil {
+		// Proxies answer with HTML or plain text
+		text := strings.TrimSpace(string(body))
+		if strings.HasPrefix(text, "<") {
+			return "", ""
+		}
+		if len(text) > 300 {
+			text = text[:300] + "…"
+		}
+		return text, ""
+	}
+
+	var text string
+	if json.Unmarshal(envelope.Error, &text) == nil {
+		return text, ""
+	}
+	var detail struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+	}
+	if json.Unmarshal(envelope.Error, &detail) == nil && detail.Message != "" {
+		code = strings.Trim(string(detail.Code), `"`)
+		if code == "" || code == "null" {
+			code = detail.Type
+		}
+		return detail.Message, code
+	}
+	return envelope.Message, ""
+}
+
+func classify(status int, code, message string) ErrorKind {
+	lower := strings.ToLower(message)
+	switch {
+	case code == "context_length_exceeded" || strings.Contains(lower, "context length") ||
+		strings.Contains(lower, "context window") || strings.Contains(lower, "maximum context") ||
+		strings.Contains(lower, "too many tokens") || status == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLong
+	case code == "model_not_found" || (strings.Contains(lower, "model") && strings.Contains(lower, "not found")) ||
+		(strings.Contains(lower, "model") && strings.Contains(lower, "does not exist")):
+		return ErrModelNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || code == "invalid_api_key":
+		return ErrAuth
+	case status == http.StatusTooManyRequests || code == "rate_limit_exceeded":
+		return ErrRateLimited
+	case status == http.StatusNotFound:
+		return ErrEndpointNotFound
+	case status >= 500:
+		return ErrServer
+	case status >= 400:
+		return ErrBadRequest
+	}
+	return ErrUnknown
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/types"
+)
+
+// JSONMode asks the model to answer a turn with JSON
+type JSONMode struct {
+	// Schema is the JSON schema the answer must match; nil accepts any JSON
+	Schema json.RawMessage
+	// Retries is how many times the model is asked to fix an invalid answer
+	Retries int
+}
+
+// SetJSONMode makes the next StreamChat call request JSON. It applies to
+// that turn only.
+func (c *Chat) SetJSONMode(mode *JSONMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jsonMode = mode
+}
+
+// format is the value of Ollama's format field, which is either "json" or
+// the schema itself
+func (m *JSONMode) format() json.RawMessage {
+	if len(m.Schema) == 0 {
+		return json.RawMessage(`"json"`)
+	}
+	return m.Schema
+}
+
+// instruct appends the instructions to the prompt in a request's messages.
+// Only the request gets them, so the history keeps the prompt as typed.
+func (m *JSONMode) instruct(messages []types.Message, prompt types.Message) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" && messages[i].Content == prompt.Content {
+			messages[i].Content += m.instructions()
+			return
+		}
+	}
+}
+
+// instructions are appended to the prompt, since models follow the format
+// better when asked in words too and OpenAI's JSON mode requires it
+func (m *JSONMode) instructions() string {
+	if len(m.Schema) == 0 {
+		return "\n\nRespond with a single JSON value and nothing else."
+	}
+	return fmt.Sprintf("\n\nRespond with a single JSON value and nothing else, matching this JSON schema:\n%s", m.Schema)
+}
+
+// check parses an answer and validates it against the schema. It returns
+// the indented JSON, or the problems to send back to the model.
+func (m *JSONMode) check(content string) (string, []string) {
+	text := stripFences(content)
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", []string{fmt.Sprintf("not valid JSON: %v", err)}
+	}
+	if len(m.Schema) > 0 {
+		if problems := validateSchema(m.Schema, value); len(problems) > 0 {
+			return "", problems
+		}
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(text), "", "  "); err != nil {
+		return text, nil
+	}
+	return pretty.String(), nil
+}
+
+// correction is the follow-up prompt after an invalid answer
+func (m *JSONMode) correction(problems []string) string {
+	return fmt.Sprintf("Your answer was rejected:\n- %s\n\nReply again with only the corrected JSON.", strings.Join(problems, "\n- "))
+}
+
+// stripFences removes a markdown code fence, which models add even when
+// asked for raw JSON
+func stripFences(content string) string {
+	text := strings.TrimSpace(content)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
@@ -0,0 +1,329 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"llm_term/pkg/types"
+)
+
+// openAIProvider speaks the OpenAI chat completions API used by most hosted
+// and self-hosted gateways (/v1/chat/completions)
+type openAIProvider struct{}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Temperature    float64               `json:"temperature"`
+	Stream         bool                  `json:"stream"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	Tools          []types.Tool          `json:"tools,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIMessage content is a string, or a list of parts when images are attached
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall differs from Ollama's in that arguments are a JSON string,
+// which is streamed in fragments keyed by index
+type openAIToolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+func (openAIProvider) name() string {
+	return "openai"
+}
+
+func (openAIProvider) encodeRequest(request types.ChatRequest) ([]byte, error) {
+	messages := make([]openAIMessage, len(request.Messages))
+	for i, message := range request.Messages {
+		messages[i] = openAIMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			toolCall := openAIToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = string(call.Function.Arguments)
+			messages[i].ToolCalls = append(messages[i].ToolCalls, toolCall)
+		}
+		if len(message.Images) == 0 {
+			continue
+		}
+
+		parts := []openAIContentPart{{Type: "text", Text: message.Content}}
+		for _, image := range message.Images {
+			parts = append(parts, openAIContentPart{
+				Type:     "image_url",
+				ImageURL: &openAIImageURL{URL: imageDataURL(image)},
+			})
+		}
+		messages[i].Content = parts
+	}
+
+	body, err := json.Marshal(openAIRequest{
+		Model:          request.Model,
+		Messages:       messages,
+		Temperature:    request.Temperature,
+		Stream:         true,
+		StreamOptions:  &openAIStreamOptions{IncludeUsage: true},
+		Tools:          request.Tools,
+		ResponseFormat: openAIFormat(request.Format),
+	})
+	if err != nil || len(request.Options) == 0 {
+		return body, err
+	}
+	return withOptions(body, request.Options)
+}
+
+// withOptions adds sampling options such as top_p or max_tokens as
+// top-level fields, which is where OpenAI expects them. They can replace
+// the temperature but not the fields that shape the request.
+func withOptions(body []byte, options map[string]any) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range options {
+		switch name {
+		case "model", "messages", "stream", "stream_options", "tools", "response_format":
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %w", name, err)
+		}
+		fields[name] = encoded
+	}
+	return json.Marshal(fields)
+}
+
+// openAIFormat maps Ollama's format field onto response_format, which is
+// JSON mode for "json" and structured outputs for a schema
+func openAIFormat(format json.RawMessage) *openAIResponseFormat {
+	if len(format) == 0 {
+		return nil
+	}
+	if string(format) == `"json"` {
+		return &openAIResponseFormat{Type: "json_object"}
+	}
+	return &openAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &openAIJSONSchema{Name: "response", Schema: format},
+	}
+}
+
+// imageDataURL turns Ollama-style base64 image data into a data URL, which
+// needs the MIME type sniffed from the decoded bytes
+func imageDataURL(image string) string {
+	header := image
+	if len(header) > 700 {
+		header = header[:700]
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(header[:len(header)/4*4])
+	return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(decoded), image)
+}
+
+func (openAIProvider) newStream(body io.Reader) stream {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &openAIStream{scanner: scanner, start: time.Now()}
+}
+
+type openAIChunk struct {
+	Error   json.RawMessage `json:"error"`
+	Model   string          `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Role      string           `json:"role"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+			// Servers that stream reasoning use either name
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// openAIStream reads server-sent events and maps each chunk onto an
+// Ollama-style response. Usage arrives in a final chunk without choices and
+// tool calls arrive in fragments, so both are only emitted with the done
+// response at [DONE].
+type openAIStream struct {
+	scanner   *bufio.Scanner
+	start     time.Time
+	final     types.ChatResponse
+	toolCalls []*openAIToolCall
+	done      bool
+}
+
+func (s *openAIStream) Next() (types.ChatResponse, error) {
+	if s.done {
+		return types.ChatResponse{}, io.EOF
+	}
+
+	for s.scanner.Scan() {
+		line := strings.TrimSpace(s.scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			// Blank separators, comments and other SSE fields
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return s.finish(), nil
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return types.ChatResponse{}, fmt.Errorf("decoding stream: %w", err)
+		}
+		if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+			message, code := parseErrorBody([]byte(data))
+			return types.ChatResponse{}, streamError(message, code)
+		}
+		if chunk.Model != "" {
+			s.final.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			s.final.PromptEvalCount = chunk.Usage.PromptTokens
+			s.final.EvalCount = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			s.final.DoneReason = choice.FinishReason
+		}
+		for _, fragment := range choice.Delta.ToolCalls {
+			s.addToolCallFragment(fragment)
+		}
+		return types.ChatResponse{
+			Model: s.final.Model,
+			Message: types.Message{
+				Role:     "assistant",
+				Content:  choice.Delta.Content,
+				Thinking: choice.Delta.ReasoningContent + choice.Delta.Reasoning,
+			},
+		}, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return types.ChatResponse{}, err
+	}
+	// Some servers close the stream without sending [DONE]
+	return s.finish(), nil
+}
+
+func (s *openAIStream) addToolCallFragment(fragment openAIToolCall) {
+	for len(s.toolCalls) <= fragment.Index {
+		s.toolCalls = append(s.toolCalls, &openAIToolCall{})
+	}
+	call := s.toolCalls[fragment.Index]
+	if fragment.ID != "" {
+		call.ID = fragment.ID
+	}
+	call.Function.Name += fragment.Function.Name
+	call.Function.Arguments += fragment.Function.Arguments
+}
+
+func (s *openAIStream) finish() types.ChatResponse {
+	s.done = true
+	s.final.Done = true
+	s.final.Message.Role = "assistant"
+	s.final.TotalDuration = int64(time.Since(s.start))
+
+	for _, call := range s.toolCalls {
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			// Keep history encodable; the handler will reject the arguments
+			args, _ = json.Marshal(call.Function.Arguments)
+		}
+		s.final.Message.ToolCalls = append(s.final.Message.ToolCalls, types.ToolCall{
+			ID:       call.ID,
+			Function: types.ToolCallFunction{Name: call.Function.Name, Arguments: args},
+		})
+	}
+	return s.final
+}
+
+// /v1/chat/completions becomes /v1/embeddings
+func (openAIProvider) embeddingsURL(endpoint string) string {
+	if i := strings.Index(endpoint, "/v1/"); i >= 0 {
+		return endpoint[:i] + "/v1/embeddings"
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/embeddings"
+}
+
+func (openAIProvider) defaultEmbedModel() string {
+	return "text-embedding-3-small"
+}
+
+func (openAIProvider) encodeEmbedRequest(model string, inputs []string) ([]byte, error) {
+	return json.Marshal(map[string]any{"model": model, "input": inputs})
+}
+
+func (openAIProvider) decodeEmbedResponse(body io.Reader) ([][]float32, error) {
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, err
+	}
+	// Results carry their input's index and aren't guaranteed to be in order
+	embeddings := make([][]float32, len(response.Data))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(embeddings) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	return embeddings, nil
+}
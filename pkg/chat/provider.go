@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"llm_term/pkg/redact"
+	"llm_term/pkg/types"
+)
+
+// provider translates between our types and a backend's wire format
+type provider interface {
+	// name is how credentials.json refers to the provider
+	name() string
+	// encodeRequest builds the body of a streaming chat request
+	encodeRequest(request types.ChatRequest) ([]byte, error)
+	// newStream reads the streamed response body chunk by chunk
+	newStream(body io.Reader) stream
+	// embeddingsURL derives the embeddings endpoint from the chat endpoint
+	embeddingsURL(endpoint string) string
+	defaultEmbedModel() string
+	encodeEmbedRequest(model string, inputs []string) ([]byte, error)
+	decodeEmbedResponse(body io.Reader) ([][]float32, error)
+}
+
+// stream yields response chunks until it returns io.EOF
+type stream interface {
+	Next() (types.ChatResponse, error)
+}
+
+// providerFor picks the configured wire format, falling back to detecting
+// it from the endpoint
+func providerFor(name, endpoint string) provider {
+	switch name {
+	case "ollama":
+		return ollamaProvider{}
+	case "openai":
+		return openAIProvider{}
+	}
+	return detectProvider(endpoint)
+}
+
+// detectProvider uses the endpoint's path, since OpenAI-compatible servers
+// live under /v1/
+func detectProvider(endpoint string) provider {
+	if strings.Contains(endpoint, "/v1/") {
+		return openAIProvider{}
+	}
+	return ollamaProvider{}
+}
+
+// ollamaProvider speaks Ollama's /api/chat, which our types mirror directly
+type ollamaProvider struct{}
+
+func (ollamaProvider) name() string {
+	return "ollama"
+}
+
+func (ollamaProvider) encodeRequest(request types.ChatRequest) ([]byte, error) {
+	return json.Marshal(request)
+}
+
+func (ollamaProvider) newStream(body io.Reader) stream {
+	return ollamaStream{decoder: json.NewDecoder(body)}
+}
+
+// ollamaStream decodes newline-delimited JSON objects
+type ollamaStream struct {
+	decoder *json.Decoder
+}
+
+func (s ollamaStream) Next() (types.ChatResponse, error) {
+	// Errors after the response has started come as {"error": "..."}
+	var response struct {
+		types.ChatResponse
+		Error string `json:"error"`
+	}
+	if err := s.decoder.Decode(&response); err != nil {
+		return types.ChatResponse{}, err
+	}
+	if response.Error != "" {
+		return types.ChatResponse{}, streamError(response.Error, "")
+	}
+	return response.ChatResponse, nil
+}
+
+// streamError is an error the backend reported inside the stream. The
+// caller fills in which endpoint it came from.
+func streamError(message, code string) *APIError {
+	return &APIError{Kind: classify(0, code, message), Message: redact.String(message)}
+}
+
+func (ollamaProvider) embeddingsURL(endpoint string) string {
+	return ollamaURL(endpoint, "embed")
+}
+
+// ollamaURL finds another Ollama endpoint next to the chat endpoint. They
+// share an /api/ prefix, so /api/chat becomes /api/embed.
+func ollamaURL(endpoint, name string) string {
+	if i := strings.Index(endpoint, "/api/"); i >= 0 {
+		return endpoint[:i] + "/api/" + name
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/api/" + name
+}
+
+func (ollamaProvider) defaultEmbedModel() string {
+	return "nomic-embed-text"
+}
+
+func (ollamaProvider) encodeEmbedRequest(model string, inputs []string) ([]byte, error) {
+	return json.Marshal(map[string]any{"model": model, "input": inputs})
+}
+
+func (ollamaProvider) decodeEmbedResponse(body io.Reader) ([][]float32, error) {
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	err := json.NewDecoder(body).Decode(&response)
+	return response.Embeddings, err
+}
@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PullProgress is one status update from Ollama while it downloads a model.
+// Layers are reported one at a time by digest, with byte counts while they
+// download.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+// CanPull reports whether the configured endpoint is Ollama, the only
+// backend that can download models on request
+func (c *Chat) CanPull() bool {
+	cfg := c.Config()
+	_, ok := providerFor(cfg.Provider, cfg.Endpoint).(ollamaProvider)
+	return ok
+}
+
+// Pull asks the configured Ollama server to download a model, calling
+// progress for each update until the download has finished
+func (c *Chat) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	cfg := c.Config()
+	endpoint := cfg.Endpoint
+	provider := providerFor(cfg.Provider, endpoint)
+	if _, ok := provider.(ollamaProvider); !ok {
+		return errors.New("pulling models needs an Ollama endpoint")
+	}
+
+	body, err := json.Marshal(map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaURL(endpoint, "pull"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if err := authorize(request, credentialFor(endpoint, provider, cfg)); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return newAPIError(resp.StatusCode, data, target{endpoint: endpoint, model: model, provider: provider})
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var update struct {
+			PullProgress
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&update); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("pulling %s: the server stopped before the download finished", model)
+			}
+			return err
+		}
+		if update.Error != "" {
+			return fmt.Errorf("pulling %s: %s", model, update.Error)
+		}
+		progress(update.PullProgress)
+		if update.Status == "success" {
+			return nil
+		}
+	}
+}
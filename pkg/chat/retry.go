@@ -0,0 +1,231 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/redact"
+	"llm_term/pkg/types"
+)
+
+// Defaults for retrying a request that failed before the answer started
+const (
+	defaultRetries      = 2
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// target is an endpoint and the model to ask there
+type target struct {
+	endpoint   string
+	model      string
+	provider   provider
+	credential credential
+}
+
+// host is how a target is named in the chat view
+func (t target) host() string {
+	if u, err := url.Parse(t.endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return t.endpoint
+}
+
+// targets returns the configured endpoint followed by the fallbacks, which
+// are "endpoint [model]" entries. A fallback without a model uses the
+// configured one, and its wire format is detected from its path since the
+// configured provider describes the primary endpoint. The config has been
+// validated, so every entry is well-formed.
+func targets(cfg *config.Config) []target {
+	primary := providerFor(cfg.Provider, cfg.Endpoint)
+	list := []target{{endpoint: cfg.Endpoint, model: cfg.Model, provider: primary}}
+
+	for _, entry := range cfg.Fallbacks {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		fallback := target{endpoint: fields[0], model: cfg.Model, provider: detectProvider(fields[0])}
+		if len(fields) == 2 {
+			fallback.model = fields[1]
+		}
+		list = append(list, fallback)
+	}
+	for i := range list {
+		list[i].credential = credentialFor(list[i].endpoint, list[i].provider, cfg)
+	}
+	return list
+}
+
+// retryPolicy is exponential backoff with equal jitter
+type retryPolicy struct {
+	retries int
+	base    time.Duration
+}
+
+// retryPolicyFor takes the retries (attempts after the first, per endpoint)
+// and the first delay from the config, or the defaults where they're unset
+func retryPolicyFor(cfg *config.Config) retryPolicy {
+	policy := retryPolicy{retries: defaultRetries, base: defaultRetryBackoff}
+	if cfg.Retries != nil {
+		policy.retries = *cfg.Retries
+	}
+	if cfg.RetryBackoff > 0 {
+		policy.base = cfg.RetryBackoff
+	}
+	return policy
+}
+
+// delay waits between half of base*2^attempt and all of it, picked at random
+// so clients that failed together don't retry together
+func (p retryPolicy) delay(attempt int) time.Duration {
+	ceiling := p.base << attempt
+	if ceiling > maxRetryBackoff || ceiling <= 0 {
+		ceiling = maxRetryBackoff
+	}
+	return ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)+1))
+}
+
+// retryable reports whether trying again might help: the server couldn't be
+// reached, dropped the connection, is overloaded or failed on its side.
+// Anything else, such as a bad URL, an unknown host or a bad certificate,
+// fails the same way again.
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	// An address that can't be parsed, such as a bad port
+	var addrErr *net.AddrError
+	if errors.As(err, &addrErr) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// rejected reports whether an error is about the request rather than the
+// endpoint, so another endpoint would turn it down too
+func rejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.Kind == ErrBadRequest || apiErr.Kind == ErrContextTooLong)
+}
+
+// connect sends the request to each target in turn, starting at *current,
+// retrying with backoff while errors are retryable. Errors that retrying
+// won't fix, such as a missing model or a bad key, move on to the next
+// target straight away, unless the request itself was rejected. On success
+// *current is the target that answered. cfg is the turn's config, for the
+// retry policy. notify reports retries as they happen.
+func (c *Chat) connect(ctx context.Context, cfg *config.Config, list []target, current *int, request types.ChatRequest, notify func(string)) (*http.Response, error) {
+	policy := retryPolicyFor(cfg)
+	var lastErr error
+	for i := *current; i < len(list); i++ {
+		t := list[i]
+		if i > *current {
+			notify(fmt.Sprintf("%s: %v, failing over to %s (%s)", list[i-1].host(), summarize(lastErr), t.host(), t.model))
+		}
+		request.Model = t.model
+
+		for attempt := 0; ; attempt++ {
+			resp, err := post(ctx, t, request)
+			if err == nil {
+				*current = i
+				return resp, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			if rejected(err) {
+				return nil, err
+			}
+			if !retryable(err) || attempt >= policy.retries {
+				break
+			}
+
+			wait := policy.delay(attempt)
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.retryAfter > 0 && apiErr.retryAfter <= maxRetryBackoff {
+				wait = apiErr.retryAfter
+			}
+			notify(fmt.Sprintf("%s: %v, retrying in %s (%d/%d)", t.host(), summarize(err), wait.Round(100*time.Millisecond), attempt+1, policy.retries))
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// post sends one request and returns the response if the server accepted it
+func post(ctx context.Context, t target, request types.ChatRequest) (*http.Response, error) {
+	body, err := t.provider.encodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	// A key that can't be found won't turn up on retry
+	if err := authorize(httpRequest, t.credential); err != nil {
+		return nil, &APIError{Kind: ErrAuth, Message: redact.String(err.Error()), Endpoint: t.host(), Model: t.model}
+	}
+
+	resp, err := http.DefaultClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := newAPIError(resp.StatusCode, data, t)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, apiErr
+}
+
+// summarize shortens an error for a one-line retry notice
+func summarize(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("%s (HTTP %d)", strings.ToLower(apiErr.Kind.String()), apiErr.Status)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
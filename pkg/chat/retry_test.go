@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Post", URL: "http://localhost:11434/api/chat", Err: err}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		rejected  bool
+	}{
+		{"connection refused", urlErr(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), true, false},
+		{"connection reset", urlErr(fmt.Errorf("read: %w", syscall.ECONNRESET)), true, false},
+		{"closed mid-response", urlErr(io.ErrUnexpectedEOF), true, false},
+		{"closed before responding", urlErr(io.EOF), true, false},
+		{"timeout", urlErr(context.DeadlineExceeded), true, false},
+		{"network unreachable", urlErr(&net.OpError{Op: "dial", Err: syscall.ENETUNREACH}), true, false},
+		{"unknown host", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "lcoalhost", IsNotFound: true}}), false, false},
+		{"DNS server unreachable", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "server misbehaving", Name: "localhost", IsTemporary: true}}), true, false},
+		{"DNS timeout", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", Name: "localhost", IsTimeout: true}}), true, false},
+		{"bad port", urlErr(&net.OpError{Op: "dial", Err: &net.AddrError{Err: "invalid port", Addr: "localhost:99999"}}), false, false},
+		{"bad scheme", urlErr(errors.New(`unsupported protocol scheme "htp"`)), false, false},
+		{"rate limited", &APIError{Kind: ErrRateLimited}, true, false},
+		{"server error", &APIError{Kind: ErrServer}, true, false},
+		{"model not found", &APIError{Kind: ErrModelNotFound}, false, false},
+		{"bad key", &APIError{Kind: ErrAuth}, false, false},
+		{"bad request", &APIError{Kind: ErrBadRequest}, false, true},
+		{"context too long", fmt.Errorf("wrapped: %w", &APIError{Kind: ErrContextTooLong}), false, true},
+	}
+	for _, test := range tests {
+		if got := retryable(test.err); got != test.retryable {
+			t.Errorf("%s: retryable = %v, want %v", test.name, got, test.retryable)
+		}
+		if got := rejected(test.err); got != test.rejected {
+			t.Errorf("%s: rejected = %v, want %v", test.name, got, test.rejected)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := retryPolicy{retries: 5, base: 100 * time.Millisecond}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		// Capped, including when the shift overflows
+		{10, maxRetryBackoff / 2, maxRetryBackoff},
+		{70, maxRetryBackoff / 2, maxRetryBackoff},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			if d := policy.delay(test.attempt); d < test.min || d > test.max {
+				t.Fatalf("delay(%d) = %s, want between %s and %s", test.attempt, d, test.min, test.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum number of problems reported back to the model at once
+const maxSchemaErrors = 10
+
+// validateSchema checks a decoded JSON value against a JSON schema. It
+// covers the parts of the spec used for structured output: type, enum,
+// const, properties, required, additionalProperties, items, the usual
+// string, number and array bounds, pattern and allOf/anyOf/oneOf. $ref and
+// formats are not checked.
+func validateSchema(schema json.RawMessage, value any) []string {
+	var root any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return []string{fmt.Sprintf("invalid schema: %v", err)}
+	}
+	v := &schemaValidator{}
+	v.validate(root, value, "$")
+	return v.errors
+}
+
+type schemaValidator struct {
+	errors []string
+}
+
+func (v *schemaValidator) fail(path, format string, args ...any) {
+	if len(v.errors) < maxSchemaErrors {
+		v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *schemaValidator) validate(schema any, value any, path string) {
+	switch s := schema.(type) {
+	case bool:
+		// true accepts anything and false accepts nothing
+		if !s {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]any:
+		v.validateObject(s, value, path)
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]any, value any, path string) {
+	if types, ok := schemaTypes(schema["type"]); ok {
+		matched := false
+		for _, t := range types {
+			if hasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), typeName(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range enum {
+			if jsonEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compactJSON(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		v.fail(path, "must be %s", compactJSON(constant))
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(value)
+		if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+			v.fail(path, "must be at least %v characters", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+			v.fail(path, "must be at most %v characters", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+				v.fail(path, "must match %s", pattern)
+			}
+		}
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && value < n {
+			v.fail(path, "must be at least %v", n)
+		}
+		if n, ok := number(schema["maximum"]); ok && value > n {
+			v.fail(path, "must be at most %v", n)
+		}
+		if n, ok := number(schema["exclusiveMinimum"]); ok && value <= n {
+			v.fail(path, "must be greater than %v", n)
+		}
+		if n, ok := number(schema["exclusiveMaximum"]); ok && value >= n {
+			v.fail(path, "must be less than %v", n)
+		}
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(value)) < n {
+			v.fail(path, "must have at least %v items", n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(value)) > n {
+			v.fail(path, "must have at most %v items", n)
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range value {
+				v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case map[string]any:
+		v.validateProperties(schema, value, path)
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && matching(anyOf, value, path) == 0 {
+		v.fail(path, "doesn't match any of the allowed schemas")
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if n := matching(oneOf, value, path); n != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matches %d", n)
+		}
+	}
+}
+
+func (v *schemaValidator) validateProperties(schema map[string]any, value map[string]any, path string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := value[name]; !present {
+					v.fail(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "." + name
+		if property, ok := properties[name]; ok {
+			v.validate(property, value[name], childPath)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			v.fail(path, "unexpected property %q", name)
+			continue
+		}
+		v.validate(additional, value[name], childPath)
+	}
+}
+
+// matching counts the subschemas that accept a value
+func matching(schemas []any, value any, path string) int {
+	count := 0
+	for _, sub := range schemas {
+		v := &schemaValidator{}
+		v.validate(sub, value, path)
+		if len(v.errors) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(value any) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func jsonEqual(a, b any) bool {
+	return compactJSON(a) == compactJSON(b)
+}
+
+// compactJSON encodes a value for comparisons and messages. Map keys are
+// sorted by encoding/json, so equal objects encode the same.
+func compactJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		want   []string
+	}{
+		{"empty schema", `{}`, `[1, "a"]`, nil},
+		{"true", `true`, `null`, nil},
+		{"false", `false`, `1`, []string{"$: no value is allowed here"}},
+		{"type", `{"type": "string"}`, `1`, []string{"$: expected string, got number"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer", `{"type": "integer"}`, `1.5`, []string{"$: expected integer, got number"}},
+		{"whole number is an integer", `{"type": "integer"}`, `2.0`, nil},
+		{"enum", `{"enum": ["a", "b"]}`, `"c"`, []string{`$: must be one of ["a","b"]`}},
+		{"const", `{"const": {"x": 1}}`, `{"x": 1}`, nil},
+		{"string bounds", `{"minLength": 2, "maxLength": 3}`, `"é"`, []string{"$: must be at least 2 characters"}},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"Abc"`, []string{"$: must match ^[a-z]+$"}},
+		{"number bounds", `{"minimum": 0, "exclusiveMaximum": 10}`, `10`, []string{"$: must be less than 10"}},
+		{"array bounds", `{"maxItems": 1}`, `[1, 2]`, []string{"$: must have at most 1 items"}},
+		{
+			"items",
+			`{"items": {"type": "number"}}`,
+			`[1, "two", 3, null]`,
+			[]string{"$[1]: expected number, got string", "$[3]: expected number, got null"},
+		},
+		{
+			"required and nested properties",
+			`{"type": "object", "required": ["name", "age"], "properties": {"name": {"type": "string"}, "tags": {"items": {"type": "string"}}}}`,
+			`{"name": 3, "tags": ["a", 1]}`,
+			[]string{`$: missing required property "age"`, "$.name: expected string, got number", "$.tags[1]: expected string, got number"},
+		},
+		{
+			"no additional properties",
+			`{"properties": {"a": {}}, "additionalProperties": false}`,
+			`{"a": 1, "b": 2}`,
+			[]string{`$: unexpected property "b"`},
+		},
+		{
+			"additional properties schema",
+			`{"additionalProperties": {"type": "boolean"}}`,
+			`{"a": true, "b": "no"}`,
+			[]string{"$.b: expected boolean, got string"},
+		},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, `true`, []string{"$: doesn't match any of the allowed schemas"}},
+		{"oneOf matching two", `{"oneOf": [{"minimum": 0}, {"maximum": 10}]}`, `5`, []string{"$: must match exactly one of the allowed schemas, matches 2"}},
+		{"allOf", `{"allOf": [{"minimum": 0}, {"maximum": 10}]}`, `11`, []string{"$: must be at most 10"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			got := validateSchema(json.RawMessage(test.schema), value)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaLimitsErrors(t *testing.T) {
+	var value any
+	json.Unmarshal([]byte(`[1,2,3,4,5,6,7,8,9,10,11,12]`), &value)
+	got := validateSchema(json.RawMessage(`{"items": {"type": "string"}}`), value)
+	if len(got) != maxSchemaErrors {
+		t.Errorf("got %d problems, want %d", len(got), maxSchemaErrors)
+	}
+}
+
+func TestValidateSchemaInvalid(t *testing.T) {
+	got := validateSchema(json.RawMessage(`{`), nil)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "invalid schema") {
+		t.Errorf("got %q, want an invalid schema error", got)
+	}
+}
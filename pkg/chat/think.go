@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"strings"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/types"
+)
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// thinkSplitter separates a <think>...</think> section at the start of
+// streamed content from the answer. Tags may be split across chunks, so
+// anything that could be the start of a tag is held back until the next one.
+type thinkSplitter struct {
+	inThink bool
+	// answered is set once the answer has started; a <think> tag after
+	// that is part of the answer
+	answered bool
+	pending  string
+}
+
+func (s *thinkSplitter) push(delta string) (thinking, answer string) {
+	text := s.pending + delta
+	s.pending = ""
+
+	for text != "" {
+		if s.inThink {
+			if i := strings.Index(text, thinkClose); i >= 0 {
+				thinking += text[:i]
+				text = text[i+len(thinkClose):]
+				s.inThink = false
+				continue
+			}
+			keep := partialSuffix(text, thinkClose)
+			thinking += text[:len(text)-keep]
+			s.pending = text[len(text)-keep:]
+			return thinking, answer
+		}
+
+		if s.answered {
+			return thinking, answer + text
+		}
+		// Skip whitespace before the answer or the opening tag
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		if trimmed == "" {
+			return thinking, answer
+		}
+		if strings.HasPrefix(trimmed, thinkOpen) {
+			text = trimmed[len(thinkOpen):]
+			s.inThink = true
+			continue
+		}
+		if strings.HasPrefix(thinkOpen, trimmed) {
+			s.pending = trimmed
+			return thinking, answer
+		}
+		s.answered = true
+		text = trimmed
+	}
+	return thinking, answer
+}
+
+// flush returns whatever was held back when the stream ends
+func (s *thinkSplitter) flush() (thinking, answer string) {
+	pending := s.pending
+	s.pending = ""
+	if s.inThink {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialSuffix returns the length of the longest suffix of text that is a
+// proper prefix of tag
+func partialSuffix(text, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// requestHistory is the history as sent to the model, after the persona's
+// system prompt and the turn's context if there are any. Reasoning from
+// earlier answers is left out unless send_thinking is set, since it is
+// long and models are trained without it.
+func (c *Chat) requestHistory(cfg *config.Config, turnContext string) []types.Message {
+	var messages []types.Message
+	if cfg.Persona != "" {
+		messages = append(messages, types.Message{Role: "system", Content: cfg.Persona})
+	}
+	if turnContext != "" {
+		messages = append(messages, types.Message{Role: "system", Content: turnContext})
+	}
+	c.mu.Lock()
+	messages = append(messages, c.history...)
+	c.mu.Unlock()
+	if send := cfg.SendThinking; send == nil || !*send {
+		for i := range messages {
+			messages[i].Thinking = ""
+		}
+	}
+	return messages
+}
@@ -0,0 +1,98 @@
+package chat
+
+import "testing"
+
+func TestThinkSplitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		thinking string
+		answer   string
+	}{
+		{
+			name:   "no thinking",
+			chunks: []string{"Hello", " world"},
+			answer: "Hello world",
+		},
+		{
+			name:     "whole tags",
+			chunks:   []string{"<think>plan</think>answer"},
+			thinking: "plan",
+			answer:   "answer",
+		},
+		{
+			name:     "tags split across chunks",
+			chunks:   []string{"<th", "ink>pl", "an</thi", "nk>ans", "wer"},
+			thinking: "plan",
+			answer:   "answer",
+		},
+		{
+			name:     "whitespace before the tag",
+			chunks:   []string{"\n ", " <think>x</think>", "y"},
+			thinking: "x",
+			answer:   "y",
+		},
+		{
+			name:   "think tag after the answer started",
+			chunks: []string{"a <think>b</think>"},
+			answer: "a <think>b</think>",
+		},
+		{
+			name:   "text that only looks like a tag",
+			chunks: []string{"<thi", "s is fine"},
+			answer: "<this is fine",
+		},
+		{
+			name:     "stream ends while thinking",
+			chunks:   []string{"<think>unfinished </th"},
+			thinking: "unfinished </th",
+		},
+		{
+			name:   "stream ends on a partial opening tag",
+			chunks: []string{"<thin"},
+			answer: "<thin",
+		},
+		{
+			name:     "less-than signs inside thinking",
+			chunks:   []string{"<think>a < b <", "/b</think>c"},
+			thinking: "a < b </b",
+			answer:   "c",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var s thinkSplitter
+			var thinking, answer string
+			for _, chunk := range test.chunks {
+				th, an := s.push(chunk)
+				thinking += th
+				answer += an
+			}
+			th, an := s.flush()
+			thinking += th
+			answer += an
+			if thinking != test.thinking || answer != test.answer {
+				t.Errorf("got thinking %q, answer %q; want %q, %q", thinking, answer, test.thinking, test.answer)
+			}
+		})
+	}
+}
+
+func TestPartialSuffix(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"abc", 0},
+		{"abc<", 1},
+		{"abc</thi", 5},
+		{"</think", 7},
+		{"</think>", 0},
+		{"", 0},
+	}
+	for _, test := range tests {
+		if got := partialSuffix(test.text, thinkClose); got != test.want {
+			t.Errorf("partialSuffix(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
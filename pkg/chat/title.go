@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"llm_term/pkg/types"
+)
+
+// Longest title kept from the model's answer, and how much of each message
+// it's shown to come up with one
+const (
+	maxTitleLength   = 60
+	maxTitleExcerpt  = 2000
+	titleInstruction = "Reply with a title of at most six words for the conversation above, " +
+		"in the language it's written in. Reply with the title only, without quotes or a full stop."
+)
+
+// Title asks the model for a short title for a conversation, going by its
+// first exchange. Tools and the persona are left out so the answer is just
+// the title.
+func (c *Chat) Title(ctx context.Context, messages []types.Message) (string, error) {
+	cfg := c.Config()
+
+	var exchange []types.Message
+	for _, message := range messages {
+		if (message.Role != "user" && message.Role != "assistant") || message.Content == "" {
+			continue
+		}
+		content := message.Content
+		if runes := []rune(content); len(runes) > maxTitleExcerpt {
+			content = string(runes[:maxTitleExcerpt])
+		}
+		exchange = append(exchange, types.Message{Role: message.Role, Content: content})
+		if len(exchange) == 2 {
+			break
+		}
+	}
+	if len(exchange) == 0 {
+		return "", fmt.Errorf("no messages to title")
+	}
+	exchange = append(exchange, types.Message{Role: "user", Content: titleInstruction})
+
+	request := types.ChatRequest{
+		Temperature: 1,
+		Messages:    exchange,
+		Options:     cfg.Options,
+	}
+	list := targets(cfg)
+	current := 0
+	resp, err := c.connect(ctx, cfg, list, &current, request, func(string) {})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Reasoning models think before answering, which isn't part of the title
+	var splitter thinkSplitter
+	var answer strings.Builder
+	stream := list[current].provider.newStream(resp.Body)
+	for {
+		response, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		_, text := splitter.push(response.Message.Content)
+		answer.WriteString(text)
+		if response.Done {
+			break
+		}
+	}
+	_, text := splitter.flush()
+	answer.WriteString(text)
+
+	title := cleanTitle(answer.String())
+	if title == "" {
+		return "", fmt.Errorf("the model gave an empty title")
+	}
+	return title, nil
+}
+
+// cleanTitle keeps the first line of an answer, without the quotes, labels
+// and markdown models tend to add anyway
+func cleanTitle(answer string) string {
+	title := strings.TrimSpace(answer)
+	if line, _, ok := strings.Cut(title, "\n"); ok {
+		title = line
+	}
+	title = strings.TrimSpace(strings.Trim(title, "*#_`"))
+	if label, rest, ok := strings.Cut(title, ":"); ok && strings.EqualFold(strings.Trim(label, "*_ "), "title") {
+		// The label may be in bold, as in "**Title:** ..."
+		title = strings.Trim(rest, "*_ ")
+	}
+	title = strings.Trim(strings.TrimSpace(title), `"'“”‘’.`)
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength-1]) + "…"
+	}
+	return title
+}
@@ -0,0 +1,28 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"Go error handling", "Go error handling"},
+		{"  \"Go error handling.\"  ", "Go error handling"},
+		{"Title: Go error handling", "Go error handling"},
+		{"**Title:** Go error handling", "Go error handling"},
+		{"## Go error handling\n\nThis conversation is about…", "Go error handling"},
+		{"“Fixing   a\tflaky test”", "Fixing a flaky test"},
+		{"Subtitle: kept as is", "Subtitle: kept as is"},
+		{"", ""},
+		{strings.Repeat("word ", 20), strings.Repeat("word ", 12)[:maxTitleLength-1] + "…"},
+	}
+	for _, test := range tests {
+		if got := cleanTitle(test.answer); got != test.want {
+			t.Errorf("cleanTitle(%q) = %q, want %q", test.answer, got, test.want)
+		}
+	}
+}
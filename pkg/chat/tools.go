@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"llm_term/pkg/types"
+)
+
+// Maximum number of request rounds in one turn when the model keeps calling tools
+const maxToolRounds = 10
+
+// Tool is a Go function the model can call while answering
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object
+	Parameters json.RawMessage
+	// Handler runs the call. The context is cancelled if the user cancels
+	// the response.
+	Handler func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Hooks let the UI render parts of a turn that aren't answer text. They are
+// called from the streaming goroutine.
+type Hooks struct {
+	// OnToolCall is called after each tool call with its result
+	OnToolCall func(call types.ToolCall, result string, err error)
+	// OnThinking streams the model's reasoning separately from the answer
+	OnThinking func(text string)
+	// OnThinkingDone is called when the reasoning ends and the answer begins
+	OnThinkingDone func()
+	// OnModelMissing is called when the Ollama endpoint doesn't have the
+	// model. It may pull it, and returns true to send the request again.
+	OnModelMissing func(ctx context.Context, model string) bool
+}
+
+// RegisterTool makes a tool available to the model on every request
+func (c *Chat) RegisterTool(tool Tool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tools == nil {
+		c.tools = make(map[string]Tool)
+	}
+	c.tools[tool.Name] = tool
+}
+
+// UnregisterTool stops offering a tool from the next request
+func (c *Chat) UnregisterTool(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tools, name)
+}
+
+func (c *Chat) SetHooks(hooks Hooks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = hooks
+}
+
+// toolDefinitions lists registered tools in a stable order for the request
+func (c *Chat) toolDefinitions() []types.Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	definitions := make([]types.Tool, 0, len(c.tools))
+	for _, tool := range c.tools {
+		parameters := tool.Parameters
+		if len(parameters) == 0 {
+			parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		definitions = append(definitions, types.Tool{
+			Type: "function",
+			Function: types.ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// runToolCalls executes the calls from an assistant message and adds their
+// results to history as tool messages for the next request
+func (c *Chat) runToolCalls(ctx context.Context, calls []types.ToolCall) {
+	for _, call := range calls {
+		result, err := c.runTool(ctx, call)
+
+		content := result
+		if err != nil {
+			content = fmt.Sprintf("error: %v", err)
+		}
+		c.addToHistory(types.Message{
+			Role:       "tool",
+			Content:    content,
+			ToolName:   call.Function.Name,
+			ToolCallID: call.ID,
+		})
+
+		c.mu.Lock()
+		onToolCall := c.hooks.OnToolCall
+		c.mu.Unlock()
+		if onToolCall != nil {
+			onToolCall(call, result, err)
+		}
+	}
+}
+
+func (c *Chat) runTool(ctx context.Context, call types.ToolCall) (string, error) {
+	// Calls left over after a cancel still need a result for the history
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	tool, ok := c.tools[call.Function.Name]
+	c.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	args := call.Function.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return tool.Handler(ctx, args)
+}
@@ -0,0 +1,80 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Copy puts text on the system clipboard. It always emits an OSC 52 escape
+// sequence, which the terminal forwards to the local clipboard even over SSH,
+// and additionally pipes the text to a native clipboard tool when running
+// locally, since not every terminal honours OSC 52.
+func Copy(text string) error {
+	oscErr := copyOSC52(text)
+
+	if isRemote() {
+		return oscErr
+	}
+	nativeErr := copyNative(text)
+	if oscErr != nil && nativeErr != nil {
+		return fmt.Errorf("no clipboard available: %v; %v", oscErr, nativeErr)
+	}
+	return nil
+}
+
+func isRemote() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// copyOSC52 writes straight to the controlling terminal so the sequence isn't
+// mixed into redirected output
+func copyOSC52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+	defer tty.Close()
+
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		// tmux only passes sequences through when wrapped in a DCS with
+		// every escape doubled
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err = tty.WriteString(seq)
+	return err
+}
+
+// copyNative uses the first clipboard tool that fits the session
+func copyNative(text string) error {
+	var candidates [][]string
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = append(candidates, []string{"pbcopy"})
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"})
+	}
+
+	for _, args := range candidates {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard tool found (install wl-copy or xclip)")
+}
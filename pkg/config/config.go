@@ -0,0 +1,525 @@
+// Package config loads llm_term's settings from config.toml, the
+// environment and command-line flags, in increasing order of precedence
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+
+	"llm_term/pkg/redact"
+	"llm_term/pkg/theme"
+)
+
+// Profile is a backend and how to talk to it. The top level of config.toml
+// holds defaults that each profile in [profiles.<name>] can override.
+type Profile struct {
+	Endpoint string `toml:"endpoint"`
+	// Provider is the API format, "ollama" or "openai", detected from the
+	// endpoint when empty
+	Provider string `toml:"provider"`
+	Model    string `toml:"model"`
+	// Fallbacks are "endpoint [model]" entries to fail over to
+	Fallbacks     []string          `toml:"fallbacks"`
+	APIKey        string            `toml:"api_key"`
+	APIKeyCommand string            `toml:"api_key_command"`
+	Headers       map[string]string `toml:"headers"`
+	// Options are sampling parameters such as temperature, passed to the
+	// backend as they are
+	Options map[string]any `toml:"options"`
+	// Persona is a system prompt sent ahead of the conversation
+	Persona string `toml:"persona"`
+	// Theme names a built-in palette or one defined under [themes]
+	Theme    string   `toml:"theme"`
+	Keybinds Keybinds `toml:"keybinds"`
+	// Retries is how many times each endpoint is retried before failing
+	// over, waiting RetryBackoff before the first retry
+	Retries      *int          `toml:"retries"`
+	RetryBackoff time.Duration `toml:"retry_backoff"`
+	// SendThinking sends the reasoning from earlier answers back to the model
+	SendThinking *bool `toml:"send_thinking"`
+	// EmbedModel and EmbedEndpoint are used for /rag, derived from the chat
+	// endpoint when empty
+	EmbedModel    string `toml:"embed_model"`
+	EmbedEndpoint string `toml:"embed_endpoint"`
+	// RAGTopK is how many indexed chunks are sent with each prompt
+	RAGTopK int `toml:"rag_top_k"`
+	// JSONRetries is how many times an invalid /json answer is asked for again
+	JSONRetries *int `toml:"json_retries"`
+	// Tools lists the built-in tools to enable, "shell" and "fs"
+	Tools []string `toml:"tools"`
+	// Workspace is the directory the fs tools work in, the current one when
+	// empty
+	Workspace string `toml:"workspace"`
+	Shell     Shell  `toml:"shell"`
+}
+
+// Shell is the run_shell tool's policy. Allow and Deny are command patterns
+// where a trailing * matches any suffix.
+type Shell struct {
+	Allow []string `toml:"allow"`
+	Deny  []string `toml:"deny"`
+	// Timeout is in seconds
+	Timeout int `toml:"timeout"`
+}
+
+// Keybinds maps actions to the key sequences that run them. An action is
+// named on its own for every mode that has it, or as "mode.action"; a
+// [keybinds.visual] table is the same as prefixing its actions with
+// "visual.". An empty list unbinds the action.
+type Keybinds map[string][]string
+
+// UnmarshalTOML takes a key sequence or a list of them for each action
+func (k *Keybinds) UnmarshalTOML(data any) error {
+	table, ok := data.(map[string]any)
+	if !ok {
+		return fmt.Errorf("keybinds: expected a table, got %v", data)
+	}
+	*k = make(Keybinds)
+	return k.add("", table)
+}
+
+func (k Keybinds) add(prefix string, table map[string]any) error {
+	for name, value := range table {
+		name = prefix + name
+		switch value := value.(type) {
+		case string:
+			k[name] = nil
+			if value != "" {
+				k[name] = []string{value}
+			}
+		case []any:
+			keys := make([]string, 0, len(value))
+			for _, v := range value {
+				s, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("keybinds.%s: expected key sequences, got %v", name, v)
+				}
+				keys = append(keys, s)
+			}
+			k[name] = keys
+		case map[string]any:
+			if prefix != "" {
+				return fmt.Errorf("keybinds.%s: expected a key sequence, got a table", name)
+			}
+			if err := k.add(name+".", value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("keybinds.%s: expected a key sequence, got %v", name, value)
+		}
+	}
+	return nil
+}
+
+// file is the layout of config.toml
+type file struct {
+	Profile
+	// Use names the profile to start with
+	Use      string                   `toml:"profile"`
+	Profiles map[string]Profile       `toml:"profiles"`
+	Themes   map[string]theme.Palette `toml:"themes"`
+	// CompactKeybinds shows the keybind strip as a single line
+	CompactKeybinds bool `toml:"compact_keybinds"`
+}
+
+// Flags are the command-line settings, which override everything else
+type Flags struct {
+	Path     string
+	Profile  string
+	Endpoint string
+	Provider string
+	Model    string
+}
+
+// Config is the profile in use once every layer is applied
+type Config struct {
+	Profile
+	// ProfileName is empty when no profile was selected
+	ProfileName string
+	// Path is the config file, which may not exist
+	Path string
+	// Profiles lists the names defined in the file
+	Profiles []string
+	// Themes are the user-defined palettes
+	Themes          map[string]theme.Palette
+	CompactKeybinds bool
+	// flags are kept so a reload layers the file the same way
+	flags Flags
+}
+
+// Path returns the config file: the flag, LLM_CONFIG, or config.toml in the
+// XDG config directory (~/.config/llm_term/config.toml by default)
+func Path(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if path := os.Getenv("LLM_CONFIG"); path != "" {
+		return path, nil
+	}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "llm_term", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating config: %w", err)
+	}
+	return filepath.Join(home, ".config", "llm_term", "config.toml"), nil
+}
+
+// Load reads .env and the config file, picks the profile from the flags,
+// LLM_PROFILE or the file, and applies environment variables and flags on
+// top. A missing config file is fine as long as the environment or flags
+// name an endpoint and model.
+func Load(flags Flags) (*Config, error) {
+	// Variables already set in the environment win over .env
+	godotenv.Load()
+
+	path, err := Path(flags.Path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decode(path, data, &f); err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err) || flags.Path != "":
+		return nil, err
+	}
+	exists := err == nil
+
+	cfg := &Config{Path: path, Profile: f.Profile, Themes: f.Themes, CompactKeybinds: f.CompactKeybinds, flags: flags}
+	for name := range f.Profiles {
+		cfg.Profiles = append(cfg.Profiles, name)
+	}
+	sort.Strings(cfg.Profiles)
+
+	cfg.ProfileName = first(flags.Profile, os.Getenv("LLM_PROFILE"), f.Use)
+	if cfg.ProfileName != "" {
+		profile, ok := f.Profiles[cfg.ProfileName]
+		if !ok {
+			return nil, unknownProfile(path, exists, cfg.ProfileName, cfg.Profiles)
+		}
+		cfg.Profile = merge(cfg.Profile, profile)
+	}
+
+	cfg.Endpoint = first(flags.Endpoint, os.Getenv("LLM_ENDPOINT"), cfg.Endpoint)
+	cfg.Provider = first(flags.Provider, os.Getenv("LLM_PROVIDER"), cfg.Provider)
+	cfg.Model = first(flags.Model, os.Getenv("LLM_MODEL"), cfg.Model)
+	if fallbacks := os.Getenv("LLM_FALLBACKS"); fallbacks != "" {
+		cfg.Fallbacks = strings.Split(fallbacks, ",")
+	}
+	if key, command := os.Getenv("LLM_API_KEY"), os.Getenv("LLM_API_KEY_COMMAND"); key != "" || command != "" {
+		cfg.APIKey, cfg.APIKeyCommand = key, command
+	}
+	if headers := os.Getenv("LLM_HEADERS"); headers != "" {
+		parsed, err := parseHeaders(headers)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Headers = mergeMaps(cfg.Headers, parsed)
+	}
+	cfg.Persona = first(os.Getenv("LLM_PERSONA"), cfg.Persona)
+	cfg.Theme = first(os.Getenv("LLM_THEME"), cfg.Theme)
+	if err := applyEnv(&cfg.Profile); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	// Redact keys before any request is made, in case one is pasted
+	redact.Add(cfg.APIKey)
+	redact.Add(os.Getenv("OPENAI_API_KEY"))
+	return cfg, nil
+}
+
+// Reload reads the config file again with the same flags
+func (c *Config) Reload() (*Config, error) {
+	return Load(c.flags)
+}
+
+// applyEnv layers the retry, thinking, retrieval, JSON and tool settings
+// from the environment over the profile
+func applyEnv(p *Profile) error {
+	var err error
+	if p.Retries, err = envInt("LLM_RETRIES", p.Retries); err != nil {
+		return err
+	}
+	if backoff := os.Getenv("LLM_RETRY_BACKOFF"); backoff != "" {
+		if p.RetryBackoff, err = time.ParseDuration(backoff); err != nil {
+			return fmt.Errorf("LLM_RETRY_BACKOFF: expected a duration like 500ms, got %q", backoff)
+		}
+	}
+	if send := os.Getenv("LLM_SEND_THINKING"); send != "" {
+		parsed, err := strconv.ParseBool(send)
+		if err != nil {
+			return fmt.Errorf("LLM_SEND_THINKING: expected true or false, got %q", send)
+		}
+		p.SendThinking = &parsed
+	}
+	p.EmbedModel = first(os.Getenv("LLM_EMBED_MODEL"), p.EmbedModel)
+	p.EmbedEndpoint = first(os.Getenv("LLM_EMBED_ENDPOINT"), p.EmbedEndpoint)
+	topK, err := envInt("LLM_RAG_TOP_K", &p.RAGTopK)
+	if err != nil {
+		return err
+	}
+	p.RAGTopK = *topK
+	if p.JSONRetries, err = envInt("LLM_JSON_RETRIES", p.JSONRetries); err != nil {
+		return err
+	}
+	if tools := os.Getenv("LLM_TOOLS"); tools != "" {
+		p.Tools = splitList(tools)
+	}
+	p.Workspace = first(os.Getenv("LLM_WORKSPACE"), p.Workspace)
+	if allow := os.Getenv("LLM_SHELL_ALLOW"); allow != "" {
+		p.Shell.Allow = splitList(allow)
+	}
+	if deny := os.Getenv("LLM_SHELL_DENY"); deny != "" {
+		p.Shell.Deny = splitList(deny)
+	}
+	timeout, err := envInt("LLM_SHELL_TIMEOUT", &p.Shell.Timeout)
+	if err != nil {
+		return err
+	}
+	p.Shell.Timeout = *timeout
+	return nil
+}
+
+// envInt returns the variable's value if it's set and current otherwise
+func envInt(name string, current *int) (*int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return current, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: expected a number, got %q", name, value)
+	}
+	return &n, nil
+}
+
+// decode parses config.toml, rejecting keys it doesn't know so typos don't
+// go unnoticed
+func decode(path string, data []byte, f *file) error {
+	meta, err := toml.Decode(string(data), f)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("%s:%d: %s", path, parseErr.Position.Line, parseErr.Message)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var unknown []string
+	for _, key := range meta.Undecoded() {
+		unknown = append(unknown, key.String())
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s: unknown setting %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func unknownProfile(path string, exists bool, name string, names []string) error {
+	if !exists {
+		return fmt.Errorf("profile %q not found: %s doesn't exist", name, path)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("profile %q not found: %s defines no profiles", name, path)
+	}
+	return fmt.Errorf("profile %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
+}
+
+// validate checks the settings needed to chat
+func (c *Config) validate() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("no endpoint configured: set endpoint in %s, LLM_ENDPOINT or --endpoint", c.Path)
+	}
+	if err := validateURL(c.Endpoint); err != nil {
+		return fmt.Errorf("endpoint: %w", err)
+	}
+	if c.Model == "" {
+		return fmt.Errorf("no model configured: set model in %s, LLM_MODEL or --model", c.Path)
+	}
+	switch strings.ToLower(c.Provider) {
+	case "", "ollama", "openai":
+		c.Provider = strings.ToLower(c.Provider)
+	default:
+		return fmt.Errorf("unknown provider %q (expected ollama or openai)", c.Provider)
+	}
+	for _, entry := range c.Fallbacks {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return fmt.Errorf("fallbacks: expected \"endpoint [model]\", got %q", strings.TrimSpace(entry))
+		}
+		if err := validateURL(fields[0]); err != nil {
+			return fmt.Errorf("fallback %s: %w", fields[0], err)
+		}
+	}
+	for name := range c.Themes {
+		if _, err := theme.Resolve(name, c.Themes); err != nil {
+			return err
+		}
+	}
+	if _, err := theme.Resolve(c.Theme, c.Themes); err != nil {
+		return err
+	}
+	if c.EmbedEndpoint != "" {
+		if err := validateURL(c.EmbedEndpoint); err != nil {
+			return fmt.Errorf("embed_endpoint: %w", err)
+		}
+	}
+	for _, name := range c.Tools {
+		if name != "shell" && name != "fs" {
+			return fmt.Errorf("tools: unknown tool %q (expected shell or fs)", name)
+		}
+	}
+	for name, n := range map[string]*int{
+		"retries":       c.Retries,
+		"rag_top_k":     &c.RAGTopK,
+		"json_retries":  c.JSONRetries,
+		"shell.timeout": &c.Shell.Timeout,
+	} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("%s: must not be negative, got %d", name, *n)
+		}
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry_backoff: must not be negative, got %s", c.RetryBackoff)
+	}
+	for name, value := range c.Options {
+		switch value.(type) {
+		case int64, float64, bool, string, []any:
+		default:
+			return fmt.Errorf("options.%s: unsupported value %v", name, value)
+		}
+	}
+	return nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return nil
+}
+
+// merge applies a profile's settings over the defaults
+func merge(base, over Profile) Profile {
+	base.Endpoint = first(over.Endpoint, base.Endpoint)
+	base.Provider = first(over.Provider, base.Provider)
+	base.Model = first(over.Model, base.Model)
+	if over.Fallbacks != nil {
+		base.Fallbacks = over.Fallbacks
+	}
+	if over.APIKey != "" || over.APIKeyCommand != "" {
+		base.APIKey, base.APIKeyCommand = over.APIKey, over.APIKeyCommand
+	}
+	base.Headers = mergeMaps(base.Headers, over.Headers)
+	base.Options = mergeMaps(base.Options, over.Options)
+	base.Persona = first(over.Persona, base.Persona)
+	base.Theme = first(over.Theme, base.Theme)
+	base.Keybinds = mergeMaps(base.Keybinds, over.Keybinds)
+	if over.Retries != nil {
+		base.Retries = over.Retries
+	}
+	if over.RetryBackoff != 0 {
+		base.RetryBackoff = over.RetryBackoff
+	}
+	if over.SendThinking != nil {
+		base.SendThinking = over.SendThinking
+	}
+	base.EmbedModel = first(over.EmbedModel, base.EmbedModel)
+	base.EmbedEndpoint = first(over.EmbedEndpoint, base.EmbedEndpoint)
+	if over.RAGTopK != 0 {
+		base.RAGTopK = over.RAGTopK
+	}
+	if over.JSONRetries != nil {
+		base.JSONRetries = over.JSONRetries
+	}
+	if over.Tools != nil {
+		base.Tools = over.Tools
+	}
+	base.Workspace = first(over.Workspace, base.Workspace)
+	if over.Shell.Allow != nil {
+		base.Shell.Allow = over.Shell.Allow
+	}
+	if over.Shell.Deny != nil {
+		base.Shell.Deny = over.Shell.Deny
+	}
+	if over.Shell.Timeout != 0 {
+		base.Shell.Timeout = over.Shell.Timeout
+	}
+	return base
+}
+
+// mergeMaps returns a new map with over's entries replacing base's
+func mergeMaps[V any](base, over map[string]V) map[string]V {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	merged := make(map[string]V, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		merged[k] = v
+	}
+	return merged
+}
+
+// parseHeaders reads LLM_HEADERS, a semicolon-separated list of
+// "Name: value" pairs
+func parseHeaders(text string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(text, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("LLM_HEADERS: expected \"Name: value\", got %q", strings.TrimSpace(entry))
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// splitList reads a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,482 @@
+// Package mcp is a minimal Model Context Protocol client for servers that
+// speak JSON-RPC over stdio.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol revision we ask for; servers answer with the one they speak
+const protocolVersion = "2025-03-26"
+
+// How long a server gets to exit after its stdin is closed
+const shutdownTimeout = 2 * time.Second
+
+// Amount of stderr kept to explain why a server died
+const stderrTail = 4 * 1024
+
+// Tool is a tool offered by a server
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Resource is a piece of context a server can provide
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Prompt is a prompt template offered by a server
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Error is a JSON-RPC error returned by the server
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// message is any JSON-RPC message; which fields are set tells them apart
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Client is a connection to one running MCP server
+type Client struct {
+	Name string
+	// Server is the name and version the server reported
+	Server struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	Instructions string
+
+	cmd          *exec.Cmd
+	stdin        io.WriteCloser
+	stderr       *tailBuffer
+	capabilities map[string]json.RawMessage
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan message
+	// done is closed when the server's stdout ends
+	done chan struct{}
+	// exited is closed when the server process has exited
+	exited chan struct{}
+	err    error
+}
+
+// Start launches the server and performs the initialize handshake. The
+// context bounds the handshake, not the lifetime of the server.
+func Start(ctx context.Context, name string, config ServerConfig) (*Client, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// A plain pipe rather than StdoutPipe, so waiting for the process to
+	// exit can't close stdout before everything in it has been read
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	// Servers log to stderr, which would draw over the TUI
+	stderr := &tailBuffer{max: stderrTail}
+	cmd.Stderr = stderr
+
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdout.Close()
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+
+	c := &Client{
+		Name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  stderr,
+		pending: make(map[int64]chan message),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		close(c.exited)
+	}()
+	go c.readLoop(stdout)
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo": map[string]string{
+			"name":    "llm_term",
+			"version": "dev",
+		},
+	}
+	var result struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      json.RawMessage            `json:"serverInfo"`
+		Instructions    string                     `json:"instructions"`
+	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	json.Unmarshal(result.ServerInfo, &c.Server)
+	c.capabilities = result.Capabilities
+	c.Instructions = result.Instructions
+	return c.notify("notifications/initialized", nil)
+}
+
+// Supports reports whether the server advertised a capability such as
+// "tools", "resources" or "prompts"
+func (c *Client) Supports(capability string) bool {
+	_, ok := c.capabilities[capability]
+	return ok
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	err := c.paginate(ctx, "tools/list", func(result json.RawMessage) error {
+		var page struct {
+			Tools []Tool `json:"tools"`
+		}
+		err := json.Unmarshal(result, &page)
+		tools = append(tools, page.Tools...)
+		return err
+	})
+	return tools, err
+}
+
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	err := c.paginate(ctx, "resources/list", func(result json.RawMessage) error {
+		var page struct {
+			Resources []Resource `json:"resources"`
+		}
+		err := json.Unmarshal(result, &page)
+		resources = append(resources, page.Resources...)
+		return err
+	})
+	return resources, err
+}
+
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	err := c.paginate(ctx, "prompts/list", func(result json.RawMessage) error {
+		var page struct {
+			Prompts []Prompt `json:"prompts"`
+		}
+		err := json.Unmarshal(result, &page)
+		prompts = append(prompts, page.Prompts...)
+		return err
+	})
+	return prompts, err
+}
+
+// paginate follows nextCursor until the server has returned every page
+func (c *Client) paginate(ctx context.Context, method string, page func(json.RawMessage) error) error {
+	cursor := ""
+	for {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result json.RawMessage
+		if err := c.call(ctx, method, params, &result); err != nil {
+			return err
+		}
+		if err := page(result); err != nil {
+			return err
+		}
+		var next struct {
+			NextCursor string `json:"nextCursor"`
+		}
+		json.Unmarshal(result, &next)
+		if next.NextCursor == "" || next.NextCursor == cursor {
+			return nil
+		}
+		cursor = next.NextCursor
+	}
+}
+
+// CallTool runs a tool and returns its text content. Results the server
+// flags as errors are returned as errors so the model sees them as such.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	params := map[string]any{
+		"name":      name,
+		"arguments": args,
+	}
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", params, &result); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, content := range result.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource.Text != "" {
+				parts = append(parts, content.Resource.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource %s]", content.Resource.URI))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", content.Type, content.MimeType))
+		}
+	}
+	if len(parts) == 0 && len(result.StructuredContent) > 0 {
+		parts = append(parts, string(result.StructuredContent))
+	}
+
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// call sends a request and waits for its response
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	reply := make(chan message, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := c.write(message{JSONRPC: "2.0", ID: &id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+
+	select {
+	case response := <-reply:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(response.Result, result)
+	case <-c.done:
+		return c.exitError()
+	case <-ctx.Done():
+		// Let the server stop working on it
+		c.notify("notifications/cancelled", map[string]any{
+			"requestId": id,
+			"reason":    ctx.Err().Error(),
+		})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params any) error {
+	msg := message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+// write sends one message as a single line, which is how stdio framing works
+func (c *Client) write(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (c *Client) readLoop(stdout io.ReadCloser) {
+	defer stdout.Close()
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			c.handle(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Give a dying server a moment so its last words end up in stderr
+	select {
+	case <-c.exited:
+	case <-time.After(time.Second):
+	}
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = c.exitErrorLocked()
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *Client) handle(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		// Not JSON-RPC, most likely a stray log line
+		return
+	}
+
+	switch {
+	case msg.Method == "" && msg.ID != nil:
+		c.mu.Lock()
+		reply, ok := c.pending[*msg.ID]
+		c.mu.Unlock()
+		if ok {
+			reply <- msg
+		}
+	case msg.Method != "" && msg.ID != nil:
+		// Requests from the server; we only answer pings
+		response := message{JSONRPC: "2.0", ID: msg.ID}
+		if msg.Method == "ping" {
+			response.Result = json.RawMessage("{}")
+		} else {
+			response.Error = &Error{Code: -32601, Message: "method not found: " + msg.Method}
+		}
+		go c.write(response)
+	}
+	// Notifications such as log messages and list changes are ignored
+}
+
+func (c *Client) exitError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) exitErrorLocked() error {
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+		lines := strings.Split(tail, "\n")
+		return fmt.Errorf("server exited: %s", lines[len(lines)-1])
+	}
+	return errors.New("server exited")
+}
+
+// Close stops the server, killing it if it doesn't exit on its own
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("client closed")
+	}
+	c.mu.Unlock()
+
+	c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(shutdownTimeout):
+		c.cmd.Process.Kill()
+		<-c.exited
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
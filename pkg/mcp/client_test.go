@@ -0,0 +1,201 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as the MCP server: started with fakeServerEnv set,
+// it answers JSON-RPC on stdio instead of running the tests
+const fakeServerEnv = "LLM_TERM_FAKE_MCP_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		fakeServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer offers two tools, listed one per page: echo returns its text
+// argument and fail always reports an error
+func fakeServer() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request message
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil || request.ID == nil {
+			continue
+		}
+		var params struct {
+			Cursor    string `json:"cursor"`
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		json.Unmarshal(request.Params, &params)
+
+		var result any
+		switch request.Method {
+		case "initialize":
+			result = map[string]any{
+				"protocolVersion": protocolVersion,
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]string{"name": "fake", "version": "1.0"},
+				"instructions":    "Use echo.",
+			}
+		case "tools/list":
+			if params.Cursor == "" {
+				result = map[string]any{"tools": []Tool{{Name: "echo"}}, "nextCursor": "page2"}
+			} else {
+				result = map[string]any{"tools": []Tool{{Name: "fail"}}}
+			}
+		case "tools/call":
+			text, isError := params.Arguments.Text, false
+			if params.Name == "fail" {
+				text, isError = "it broke", true
+			}
+			result = map[string]any{
+				"content": []map[string]string{{"type": "text", "text": text}},
+				"isError": isError,
+			}
+		default:
+			data, _ := json.Marshal(message{JSONRPC: "2.0", ID: request.ID, Error: &Error{Code: -32601, Message: "method not found"}})
+			fmt.Println(string(data))
+			continue
+		}
+		raw, _ := json.Marshal(result)
+		data, _ := json.Marshal(message{JSONRPC: "2.0", ID: request.ID, Result: raw})
+		fmt.Println(string(data))
+	}
+}
+
+func startFake(t *testing.T) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := Start(ctx, "fake", ServerConfig{
+		Command: os.Args[0],
+		Env:     map[string]string{fakeServerEnv: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestInitialize(t *testing.T) {
+	client := startFake(t)
+	if client.Server.Name != "fake" || client.Server.Version != "1.0" {
+		t.Errorf("server = %+v, want fake 1.0", client.Server)
+	}
+	if client.Instructions != "Use echo." {
+		t.Errorf("instructions = %q", client.Instructions)
+	}
+	if !client.Supports("tools") || client.Supports("prompts") {
+		t.Errorf("capabilities = %v, want tools only", client.capabilities)
+	}
+}
+
+func TestListToolsFollowsPages(t *testing.T) {
+	client := startFake(t)
+	tools, err := client.ListTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "echo,fail" {
+		t.Errorf("tools = %s, want echo,fail", got)
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	client := startFake(t)
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr string
+	}{
+		{name: "echo", args: `{"text":"hello"}`, want: "hello"},
+		{name: "echo", args: ``, want: ""},
+		{name: "fail", args: `{}`, wantErr: "it broke"},
+	}
+	for _, test := range tests {
+		got, err := client.CallTool(context.Background(), test.name, json.RawMessage(test.args))
+		switch {
+		case test.wantErr != "":
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("%s(%s) error = %v, want %q", test.name, test.args, err, test.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s(%s): %v", test.name, test.args, err)
+		case got != test.want:
+			t.Errorf("%s(%s) = %q, want %q", test.name, test.args, got, test.want)
+		}
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	client := startFake(t)
+	_, err := client.ListPrompts(context.Background())
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != -32601 {
+		t.Errorf("error = %v, want method not found", err)
+	}
+}
+
+func TestChatTools(t *testing.T) {
+	client := startFake(t)
+	tools, err := client.ChatTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "fake__echo" {
+		t.Fatalf("tools = %+v, want fake__echo and fake__fail", tools)
+	}
+	got, err := tools[0].Handler(context.Background(), json.RawMessage(`{"text":"hi"}`))
+	if err != nil || got != "hi" {
+		t.Errorf("fake__echo = %q, %v; want hi", got, err)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		server, tool string
+		want         string
+	}{
+		{"git", "status", "git__status"},
+		{"my-server", "read_file", "my-server__read_file"},
+		{"files", "read.file", "files__read_file_"},
+		{"x", long, "x__" + strings.Repeat("a", 52) + "_"},
+	}
+	for _, test := range tests {
+		got := toolName(test.server, test.tool)
+		if !strings.HasPrefix(got, test.want) || len(got) > maxToolName {
+			t.Errorf("toolName(%q, %q) = %q, want it to start with %q", test.server, test.tool, got, test.want)
+		}
+	}
+
+	// Names that only differ in what had to be replaced or cut stay apart
+	collisions := [][2]string{
+		{"read.file", "read_file"},
+		{long + "1", long + "2"},
+	}
+	for _, tools := range collisions {
+		a, b := toolName("s", tools[0]), toolName("s", tools[1])
+		if a == b {
+			t.Errorf("%q and %q both became %q", tools[0], tools[1], a)
+		}
+	}
+}
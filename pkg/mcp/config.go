@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ServerConfig describes how to launch one MCP server over stdio
+type ServerConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	// Disabled keeps a server in the file without starting it
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Config uses the same "mcpServers" layout as other MCP clients so existing
+// server definitions can be copied over
+type Config struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// ConfigPath returns LLM_MCP_CONFIG if set, otherwise mcp.json in the XDG
+// config directory (~/.config/llm_term/mcp.json by default)
+func ConfigPath() (string, error) {
+	if path := os.Getenv("LLM_MCP_CONFIG"); path != "" {
+		return path, nil
+	}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "llm_term", "mcp.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating MCP config: %w", err)
+	}
+	return filepath.Join(home, ".config", "llm_term", "mcp.json"), nil
+}
+
+// LoadConfig reads the MCP config. A missing file is an empty config.
+func LoadConfig() (*Config, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, server := range config.Servers {
+		if server.Command == "" && !server.Disabled {
+			return nil, fmt.Errorf("%s: server %q has no command", path, name)
+		}
+	}
+	return &config, nil
+}
@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"llm_term/pkg/chat"
+)
+
+// Providers limit tool names to 64 letters, digits, underscores and dashes
+const maxToolName = 64
+
+// ChatTools wraps the server's tools for the chat tool-calling loop. Names
+// are prefixed with the server name so two servers can offer the same tool.
+func (c *Client) ChatTools(ctx context.Context) ([]chat.Tool, error) {
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chatTools := make([]chat.Tool, 0, len(tools))
+	for _, tool := range tools {
+		name := tool.Name
+		chatTools = append(chatTools, chat.Tool{
+			Name:        toolName(c.Name, tool.Name),
+			Description: tool.Description,
+			Parameters:  tool.InputSchema,
+			Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return c.CallTool(ctx, name, args)
+			},
+		})
+	}
+	return chatTools, nil
+}
+
+func toolName(server, tool string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '_' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	name := clean(server) + "__" + clean(tool)
+	if name == server+"__"+tool && len(name) <= maxToolName {
+		return name
+	}
+	// Replacing characters or cutting a long name can make two tools share
+	// a name, so the original names are hashed into the end of it
+	hash := fnv.New32a()
+	hash.Write([]byte(server + "\x00" + tool))
+	suffix := fmt.Sprintf("_%08x", hash.Sum32())
+	if len(name) > maxToolName-len(suffix) {
+		name = name[:maxToolName-len(suffix)]
+	}
+	return name + suffix
+}
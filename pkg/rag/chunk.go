@@ -0,0 +1,120 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits for what gets indexed
+const (
+	maxFileSize = 1024 * 1024
+	maxFiles    = 5000
+)
+
+// Chunks are cut at line boundaries and overlap a little so a passage split
+// across two chunks still shows up whole in one of them
+const (
+	maxChunkLines = 60
+	maxChunkChars = 2000
+	chunkOverlap  = 5
+)
+
+// Directories that hold dependencies or build output rather than sources
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"dist":         true,
+	"build":        true,
+	"__pycache__":  true,
+}
+
+type file struct {
+	path    string
+	content string
+	modTime time.Time
+	size    int64
+}
+
+// walkFiles reads the text files under dir one at a time and hands each to
+// visit, skipping hidden files, dependency directories, large files and
+// binaries. It returns how many files were skipped, and stops early when
+// the context is cancelled or visit fails.
+func walkFiles(ctx context.Context, dir string, visit func(file) error) (int, error) {
+	files, skipped := 0, 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		name := entry.Name()
+		if path != dir && (strings.HasPrefix(name, ".") || skippedDirs[name]) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if files >= maxFiles {
+			skipped++
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.Size() > maxFileSize || info.Size() == 0 {
+			skipped++
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+			skipped++
+			return nil
+		}
+		files++
+		return visit(file{path: path, content: string(data), modTime: info.ModTime(), size: info.Size()})
+	})
+	return skipped, err
+}
+
+// chunkFile splits a file into overlapping ranges of lines
+func chunkFile(path, content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); {
+		end, size := start, 0
+		for end < len(lines) && end-start < maxChunkLines {
+			// Always take at least one line, even an overlong one
+			if end > start && size+len(lines[end]) > maxChunkChars {
+				break
+			}
+			size += len(lines[end]) + 1
+			end++
+		}
+
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			if len(text) > maxChunkChars {
+				text = text[:maxChunkChars]
+				for !utf8.ValidString(text) {
+					text = text[:len(text)-1]
+				}
+			}
+			chunks = append(chunks, Chunk{Path: path, StartLine: start + 1, EndLine: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+		start = max(end-chunkOverlap, start+1)
+	}
+	return chunks
+}
@@ -0,0 +1,361 @@
+// Package rag indexes local files as embedded chunks and retrieves the ones
+// most similar to a prompt.
+package rag
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of chunks sent to the embeddings endpoint per request
+const embedBatchSize = 32
+
+// EmbedFunc returns one vector per input text
+type EmbedFunc func(ctx context.Context, inputs []string) ([][]float32, error)
+
+// Chunk is a range of lines from an indexed file
+type Chunk struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Text      string
+	// Vector is normalized so similarity is a dot product
+	Vector []float32
+}
+
+// Source is a directory added to the index
+type Source struct {
+	Dir   string
+	Added time.Time
+	// Files maps each indexed path to its state when it was embedded, so
+	// re-adding a directory only embeds files that changed
+	Files map[string]FileState
+}
+
+type FileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Hit is a retrieved chunk and its cosine similarity to the query
+type Hit struct {
+	Chunk
+	Score float64
+}
+
+// Index holds every chunk in memory and is saved to a single gob file
+type Index struct {
+	// Model is the embedding model every vector came from; vectors from
+	// different models can't be compared
+	Model   string
+	Sources map[string]*Source
+	Chunks  []Chunk
+
+	mu   sync.RWMutex
+	path string
+}
+
+// Open loads the index at LLM_RAG_DIR, or $XDG_DATA_HOME/llm_term/rag
+// (~/.local/share/llm_term/rag by default). A missing index is empty.
+func Open() (*Index, error) {
+	dir, err := defaultDir()
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{
+		Sources: make(map[string]*Source),
+		path:    filepath.Join(dir, "index.gob"),
+	}
+
+	f, err := os.Open(idx.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(idx); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", idx.path, err)
+	}
+	if idx.Sources == nil {
+		idx.Sources = make(map[string]*Source)
+	}
+	return idx, nil
+}
+
+func defaultDir() (string, error) {
+	if dir := os.Getenv("LLM_RAG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "llm_term", "rag"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating RAG index: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "llm_term", "rag"), nil
+}
+
+// save writes the index atomically; the caller holds at least a read lock
+func (idx *Index) save() error {
+	dir := filepath.Dir(idx.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".index-*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(idx); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), idx.path)
+}
+
+// Path is the file the index is saved to
+func (idx *Index) Path() string {
+	return idx.path
+}
+
+// Empty reports whether there is anything to retrieve
+func (idx *Index) Empty() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.Chunks) == 0
+}
+
+// EmbedModel is the model queries must be embedded with
+func (idx *Index) EmbedModel() string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.Model
+}
+
+// List returns the indexed directories with their chunk counts
+func (idx *Index) List() ([]Source, map[string]int) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	sources := make([]Source, 0, len(idx.Sources))
+	for _, source := range idx.Sources {
+		sources = append(sources, *source)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Dir < sources[j].Dir })
+
+	counts := make(map[string]int)
+	for _, chunk := range idx.Chunks {
+		for _, source := range sources {
+			if _, ok := source.Files[chunk.Path]; ok {
+				counts[source.Dir]++
+			}
+		}
+	}
+	return sources, counts
+}
+
+// AddStats summarizes what Add did
+type AddStats struct {
+	Files    int
+	Embedded int
+	Chunks   int
+	Skipped  int
+}
+
+// Add indexes every text file under dir. Files that are unchanged since the
+// last time dir was added keep their vectors. progress is called with the
+// number of chunks embedded so far. Cancelling ctx stops it and leaves the
+// index as it was.
+func (idx *Index) Add(ctx context.Context, dir, model string, embed EmbedFunc, progress func(done, total int)) (AddStats, error) {
+	var stats AddStats
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return stats, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return stats, err
+	} else if !info.IsDir() {
+		return stats, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	idx.mu.RLock()
+	if idx.Model != "" && idx.Model != model && len(idx.Chunks) > 0 {
+		idx.mu.RUnlock()
+		return stats, fmt.Errorf("the index was built with %s, not %s; clear it first", idx.Model, model)
+	}
+	previous := idx.Sources[dir]
+	existing := make(map[string][]Chunk)
+	if previous != nil {
+		for _, chunk := range idx.Chunks {
+			if _, ok := previous.Files[chunk.Path]; ok {
+				existing[chunk.Path] = append(existing[chunk.Path], chunk)
+			}
+		}
+	}
+	idx.mu.RUnlock()
+
+	// Files are chunked as they're read rather than all held at once
+	source := &Source{Dir: dir, Added: time.Now(), Files: make(map[string]FileState)}
+	var chunks, pending []Chunk
+	skipped, err := walkFiles(ctx, dir, func(file file) error {
+		state := FileState{ModTime: file.modTime, Size: file.size}
+		source.Files[file.path] = state
+		if previous != nil && previous.Files[file.path] == state {
+			chunks = append(chunks, existing[file.path]...)
+			return nil
+		}
+		pending = append(pending, chunkFile(file.path, file.content)...)
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.Files = len(source.Files)
+	stats.Skipped = skipped
+
+	for start := 0; start < len(pending); start += embedBatchSize {
+		if progress != nil {
+			progress(start, len(pending))
+		}
+		end := min(start+embedBatchSize, len(pending))
+		inputs := make([]string, end-start)
+		for i := range inputs {
+			inputs[i] = embedText(pending[start+i])
+		}
+		vectors, err := embed(ctx, inputs)
+		if err != nil {
+			return stats, fmt.Errorf("embedding: %w", err)
+		}
+		for i, vector := range vectors {
+			pending[start+i].Vector = normalize(vector)
+		}
+	}
+	chunks = append(chunks, pending...)
+	stats.Embedded = len(pending)
+	stats.Chunks = len(chunks)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(dir)
+	idx.Model = model
+	idx.Sources[dir] = source
+	idx.Chunks = append(idx.Chunks, chunks...)
+	return stats, idx.save()
+}
+
+// embedText prefixes the path so file names count towards similarity
+func embedText(chunk Chunk) string {
+	return filepath.Base(chunk.Path) + "\n" + chunk.Text
+}
+
+// Remove drops a directory and its chunks from the index
+func (idx *Index) Remove(dir string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.Sources[dir]; !ok {
+		return false, nil
+	}
+	idx.removeLocked(dir)
+	return true, idx.save()
+}
+
+func (idx *Index) removeLocked(dir string) {
+	source, ok := idx.Sources[dir]
+	if !ok {
+		return
+	}
+	delete(idx.Sources, dir)
+	kept := idx.Chunks[:0]
+	for _, chunk := range idx.Chunks {
+		// Another source may cover the same file if directories overlap
+		if _, ok := source.Files[chunk.Path]; !ok || idx.coveredLocked(chunk.Path) {
+			kept = append(kept, chunk)
+		}
+	}
+	idx.Chunks = kept
+}
+
+func (idx *Index) coveredLocked(path string) bool {
+	for _, source := range idx.Sources {
+		if _, ok := source.Files[path]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes everything from the index
+func (idx *Index) Clear() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.Model = ""
+	idx.Sources = make(map[string]*Source)
+	idx.Chunks = nil
+	return idx.save()
+}
+
+// Search returns the k chunks most similar to the query vector
+func (idx *Index) Search(query []float32, k int) []Hit {
+	query = normalize(query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	hits := make([]Hit, 0, len(idx.Chunks))
+	for _, chunk := range idx.Chunks {
+		if len(chunk.Vector) != len(query) {
+			continue
+		}
+		// Overlapping directories can hold the same chunk twice
+		key := fmt.Sprintf("%s:%d", chunk.Path, chunk.StartLine)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var score float64
+		for i, v := range chunk.Vector {
+			score += float64(v) * float64(query[i])
+		}
+		hits = append(hits, Hit{Chunk: chunk, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
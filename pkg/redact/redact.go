@@ -0,0 +1,51 @@
+// Package redact keeps API keys out of text that leaves the process, like
+// saved sessions and error messages
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces a secret
+const Placeholder = "[REDACTED]"
+
+// Secrets shorter than this aren't registered, so a stray short value can't
+// blank out ordinary words
+const minSecretLength = 8
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+)
+
+// Add registers a secret to be redacted from now on
+func Add(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// String replaces every registered secret in text
+func String(text string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, Placeholder)
+	}
+	return text
+}
@@ -0,0 +1,418 @@
+package session
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"llm_term/pkg/types"
+)
+
+// Import parses a chat export and returns one session per conversation.
+// Supported formats are ChatGPT's conversations.json, Open WebUI exports and
+// plain OpenAI-style messages JSON ({"messages": [...]}, a list of those, or a
+// bare array of messages).
+func Import(data []byte) ([]*Session, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty export")
+	}
+
+	// Exports are either a list of conversations or a single conversation;
+	// normalize to a list and sniff the format from the first element.
+	var items []json.RawMessage
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("parsing export: %w", err)
+		}
+	} else {
+		items = []json.RawMessage{data}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("export contains no conversations")
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(items[0], &probe); err != nil {
+		return nil, fmt.Errorf("parsing export: %w", err)
+	}
+
+	switch {
+	case probe["mapping"] != nil:
+		return importChatGPT(items)
+	case probe["chat"] != nil:
+		return importOpenWebUI(items)
+	case probe["messages"] != nil:
+		var sessions []*Session
+		for i, item := range items {
+			imported, err := importOpenAI(item)
+			if err != nil {
+				if len(items) == 1 {
+					return nil, err
+				}
+				return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+			}
+			sessions = append(sessions, imported...)
+		}
+		return sessions, nil
+	case probe["role"] != nil:
+		// A bare array of messages is a single conversation
+		return importOpenAI(data)
+	}
+	return nil, fmt.Errorf("unrecognized export format")
+}
+
+// mapRole maps roles from other tools onto the roles we send to the model.
+// Tool and function messages are dropped since their calls aren't imported.
+func mapRole(role string) (string, bool) {
+	switch role {
+	case "user", "human":
+		return "user", true
+	case "assistant", "model":
+		return "assistant", true
+	case "system", "developer":
+		return "system", true
+	}
+	return "", false
+}
+
+func newImported(source, sourceID, title, model string, created, updated time.Time, messages []types.Message) *Session {
+	if sourceID == "" {
+		sourceID = contentID(messages)
+	}
+	if title == "" {
+		title = DefaultTitle(messages)
+	}
+	if created.IsZero() {
+		created = time.Now()
+	}
+	if updated.IsZero() {
+		updated = created
+	}
+	return &Session{
+		ID:       NewID(),
+		Title:    title,
+		Model:    model,
+		Source:   source,
+		SourceID: sourceID,
+		Created:  created,
+		Updated:  updated,
+		Messages: messages,
+	}
+}
+
+// first returns the first non-empty value
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// contentID stands in for the id of a conversation whose export has none,
+// so the same messages are recognized when imported again
+func contentID(messages []types.Message) string {
+	hash := sha256.New()
+	for _, message := range messages {
+		fmt.Fprintf(hash, "%s\x00%s\x00", message.Role, message.Content)
+	}
+	return "sha256-" + hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// unixTime converts the fractional or integer epoch seconds used by exports
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	// Some exports use milliseconds
+	if seconds > 1e12 {
+		seconds /= 1000
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// textContent extracts the text from either a plain string or a list of
+// content parts ({"type": "text", "text": ...} or bare strings).
+func textContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		var s string
+		if err := json.Unmarshal(part, &s); err == nil {
+			texts = append(texts, s)
+			continue
+		}
+		var obj struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &obj); err == nil && obj.Text != "" {
+			texts = append(texts, obj.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type chatGPTConversation struct {
+	ID               string                 `json:"id"`
+	ConversationID   string                 `json:"conversation_id"`
+	Title            string                 `json:"title"`
+	CreateTime       float64                `json:"create_time"`
+	UpdateTime       float64                `json:"update_time"`
+	CurrentNode      string                 `json:"current_node"`
+	DefaultModelSlug string                 `json:"default_model_slug"`
+	Mapping          map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Content struct {
+		ContentType string          `json:"content_type"`
+		Parts       json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+func importChatGPT(items []json.RawMessage) ([]*Session, error) {
+	sessions := make([]*Session, 0, len(items))
+	for i, item := range items {
+		var conv chatGPTConversation
+		if err := json.Unmarshal(item, &conv); err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+
+		// The mapping is a tree of edits and regenerations; the visible
+		// conversation is the path from the root to the current node.
+		leaf, err := chatGPTLeaf(conv)
+		if err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+		var path []chatGPTMessage
+		visited := make(map[string]bool)
+		for id := leaf; id != ""; id = conv.Mapping[id].Parent {
+			node, ok := conv.Mapping[id]
+			if !ok {
+				break
+			}
+			if visited[id] {
+				return nil, fmt.Errorf("conversation %d: message %s is its own ancestor", i+1, id)
+			}
+			visited[id] = true
+			if node.Message != nil {
+				path = append(path, *node.Message)
+			}
+		}
+
+		var messages []types.Message
+		for j := len(path) - 1; j >= 0; j-- {
+			msg := path[j]
+			role, ok := mapRole(msg.Author.Role)
+			if !ok || msg.Metadata.Hidden {
+				continue
+			}
+			if ct := msg.Content.ContentType; ct != "text" && ct != "multimodal_text" {
+				continue
+			}
+			content := textContent(msg.Content.Parts)
+			if strings.TrimSpace(content) == "" {
+				continue
+			}
+			messages = append(messages, types.Message{Role: role, Content: content})
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		sessions = append(sessions, newImported("chatgpt", first(conv.ConversationID, conv.ID), conv.Title, conv.DefaultModelSlug,
+			unixTime(conv.CreateTime), unixTime(conv.UpdateTime), messages))
+	}
+	return sessions, nil
+}
+
+// chatGPTLeaf returns the current node, or the newest branch if the export
+// doesn't record one.
+func chatGPTLeaf(conv chatGPTConversation) (string, error) {
+	if _, ok := conv.Mapping[conv.CurrentNode]; ok {
+		return conv.CurrentNode, nil
+	}
+	var root string
+	for id, node := range conv.Mapping {
+		if node.Parent == "" {
+			root = id
+			break
+		}
+	}
+	id := root
+	visited := make(map[string]bool)
+	for {
+		if visited[id] {
+			return "", fmt.Errorf("message %s is its own descendant", id)
+		}
+		visited[id] = true
+		children := conv.Mapping[id].Children
+		if len(children) == 0 {
+			return id, nil
+		}
+		id = children[len(children)-1]
+	}
+}
+
+type openWebUIExport struct {
+	ID        string  `json:"id"`
+	Title     string  `json:"title"`
+	CreatedAt float64 `json:"created_at"`
+	UpdatedAt float64 `json:"updated_at"`
+	Chat      struct {
+		Title    string             `json:"title"`
+		Models   []string           `json:"models"`
+		Messages []openWebUIMessage `json:"messages"`
+		History  struct {
+			CurrentID string                      `json:"currentId"`
+			Messages  map[string]openWebUIMessage `json:"messages"`
+		} `json:"history"`
+	} `json:"chat"`
+}
+
+type openWebUIMessage struct {
+	ParentID  string          `json:"parentId"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	Model     string          `json:"model"`
+	Timestamp float64         `json:"timestamp"`
+}
+
+func importOpenWebUI(items []json.RawMessage) ([]*Session, error) {
+	sessions := make([]*Session, 0, len(items))
+	for i, item := range items {
+		var export openWebUIExport
+		if err := json.Unmarshal(item, &export); err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i+1, err)
+		}
+
+		// Prefer the history tree since the flat list may include
+		// regenerated answers that aren't on the selected branch.
+		source := export.Chat.Messages
+		if history := export.Chat.History; history.CurrentID != "" {
+			var path []openWebUIMessage
+			visited := make(map[string]bool)
+			for id := history.CurrentID; id != ""; {
+				msg, ok := history.Messages[id]
+				if !ok {
+					break
+				}
+				if visited[id] {
+					return nil, fmt.Errorf("conversation %d: message %s is its own ancestor", i+1, id)
+				}
+				visited[id] = true
+				path = append(path, msg)
+				id = msg.ParentID
+			}
+			if len(path) > 0 {
+				source = make([]openWebUIMessage, 0, len(path))
+				for j := len(path) - 1; j >= 0; j-- {
+					source = append(source, path[j])
+				}
+			}
+		}
+
+		var messages []types.Message
+		var model string
+		for _, msg := range source {
+			role, ok := mapRole(msg.Role)
+			if !ok {
+				continue
+			}
+			content := textContent(msg.Content)
+			if strings.TrimSpace(content) == "" {
+				continue
+			}
+			if msg.Model != "" {
+				model = msg.Model
+			}
+			messages = append(messages, types.Message{Role: role, Content: content})
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		if model == "" && len(export.Chat.Models) > 0 {
+			model = export.Chat.Models[0]
+		}
+
+		title := export.Title
+		if title == "" {
+			title = export.Chat.Title
+		}
+		sessions = append(sessions, newImported("open-webui", export.ID, title, model,
+			unixTime(export.CreatedAt), unixTime(export.UpdatedAt), messages))
+	}
+	return sessions, nil
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+func importOpenAI(data []byte) ([]*Session, error) {
+	var export struct {
+		ID       string          `json:"id"`
+		Model    string          `json:"model"`
+		Title    string          `json:"title"`
+		Messages []openAIMessage `json:"messages"`
+	}
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &export.Messages); err != nil {
+			return nil, fmt.Errorf("parsing messages: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("parsing messages: %w", err)
+	}
+
+	var messages []types.Message
+	for _, msg := range export.Messages {
+		role, ok := mapRole(msg.Role)
+		if !ok {
+			continue
+		}
+		content := textContent(msg.Content)
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		messages = append(messages, types.Message{Role: role, Content: content})
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("export contains no messages")
+	}
+
+	return []*Session{newImported("openai", export.ID, export.Title, export.Model, time.Time{}, time.Time{}, messages)}, nil
+}
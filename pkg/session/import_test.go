@@ -0,0 +1,239 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// summary writes the parts of imported sessions the tests compare, one line
+// per session
+func summary(sessions []*Session) string {
+	lines := make([]string, len(sessions))
+	for i, s := range sessions {
+		messages := make([]string, len(s.Messages))
+		for j, m := range s.Messages {
+			messages[j] = m.Role + ":" + m.Content
+		}
+		lines[i] = fmt.Sprintf("%s|%s|%s|%s", s.Source, s.Title, s.Model, strings.Join(messages, ","))
+	}
+	return strings.Join(lines, "\n")
+}
+
+const chatGPTSample = `[{
+	"conversation_id": "conv-1",
+	"title": "Greetings",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100,
+	"current_node": "c",
+	"default_model_slug": "gpt-4o",
+	"mapping": {
+		"root": {"parent": "", "children": ["s"]},
+		"s": {"parent": "root", "children": ["a"], "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+		"a": {"parent": "s", "children": ["b", "b2"], "message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["hi"]}}},
+		"b": {"parent": "a", "children": [], "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["first try"]}}},
+		"b2": {"parent": "a", "children": ["c"], "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["hello"]}}},
+		"c": {"parent": "b2", "children": [], "message": {"author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["dropped"]}}}
+	}
+}]`
+
+const openWebUISample = `[{
+	"id": "chat-1",
+	"title": "Weather",
+	"created_at": 1700000000,
+	"updated_at": 1700000000000,
+	"chat": {
+		"models": ["llama3"],
+		"messages": [{"role": "user", "content": "flat list"}],
+		"history": {
+			"currentId": "m3",
+			"messages": {
+				"m1": {"role": "user", "content": "rain?"},
+				"m2": {"parentId": "m1", "role": "assistant", "content": "old answer", "model": "mistral"},
+				"m3": {"parentId": "m1", "role": "assistant", "content": [{"type": "text", "text": "no rain"}], "model": "qwen"}
+			}
+		}
+	}
+}]`
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+		err  string
+	}{
+		{
+			name: "ChatGPT follows the current branch",
+			data: chatGPTSample,
+			want: "chatgpt|Greetings|gpt-4o|user:hi,assistant:hello",
+		},
+		{
+			name: "ChatGPT without a current node takes the newest branch",
+			data: strings.Replace(chatGPTSample, `"current_node": "c"`, `"current_node": ""`, 1),
+			want: "chatgpt|Greetings|gpt-4o|user:hi,assistant:hello",
+		},
+		{
+			name: "Open WebUI follows the history tree",
+			data: openWebUISample,
+			want: "open-webui|Weather|qwen|user:rain?,assistant:no rain",
+		},
+		{
+			name: "Open WebUI without history uses the flat list",
+			data: `{"chat": {"title": "Flat", "models": ["llama3"], "messages": [{"role": "user", "content": "hi"}]}}`,
+			want: "open-webui|Flat|llama3|user:hi",
+		},
+		{
+			name: "OpenAI messages object",
+			data: `{"model": "gpt-4", "messages": [{"role": "developer", "content": "be brief"}, {"role": "user", "content": [{"type": "text", "text": "a"}, "b"]}]}`,
+			want: "openai|a b|gpt-4|system:be brief,user:a\nb",
+		},
+		{
+			name: "list of OpenAI conversations",
+			data: `[{"title": "One", "messages": [{"role": "user", "content": "1"}]}, {"title": "Two", "messages": [{"role": "human", "content": "2"}]}]`,
+			want: "openai|One||user:1\nopenai|Two||user:2",
+		},
+		{
+			name: "bare array of messages",
+			data: `[{"role": "user", "content": "q"}, {"role": "model", "content": "a"}, {"role": "function", "content": "x"}]`,
+			want: "openai|q||user:q,assistant:a",
+		},
+		{
+			name: "ChatGPT conversation without messages is skipped",
+			data: `[{"title": "Empty", "mapping": {"r": {"parent": "", "children": []}}}]`,
+			want: "",
+		},
+		{
+			name: "ChatGPT parent cycle",
+			data: `[{"current_node": "a", "mapping": {"a": {"parent": "b"}, "b": {"parent": "a"}}}]`,
+			err:  "conversation 1: message a is its own ancestor",
+		},
+		{
+			name: "ChatGPT children cycle",
+			data: `[{"mapping": {"r": {"parent": "", "children": ["x"]}, "x": {"parent": "r", "children": ["r"]}}}]`,
+			err:  "conversation 1: message r is its own descendant",
+		},
+		{
+			name: "Open WebUI parent cycle",
+			data: `[{"chat": {"history": {"currentId": "a", "messages": {"a": {"parentId": "b", "role": "user", "content": "x"}, "b": {"parentId": "a", "role": "user", "content": "y"}}}}}]`,
+			err:  "conversation 1: message a is its own ancestor",
+		},
+		{
+			name: "one of several OpenAI conversations is empty",
+			data: `[{"messages": [{"role": "user", "content": "1"}]}, {"messages": []}]`,
+			err:  "conversation 2: export contains no messages",
+		},
+		{name: "empty", data: "  ", err: "empty export"},
+		{name: "empty list", data: "[]", err: "export contains no conversations"},
+		{name: "unknown format", data: `{"foo": 1}`, err: "unrecognized export format"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sessions, err := Import([]byte(test.data))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(sessions); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestImportTimes(t *testing.T) {
+	sessions, err := Import([]byte(openWebUISample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// updated_at is in milliseconds
+	created, updated := time.Unix(1700000000, 0), time.Unix(1700000000, 0)
+	if !sessions[0].Created.Equal(created) || !sessions[0].Updated.Equal(updated) {
+		t.Errorf("times = %v, %v; want %v, %v", sessions[0].Created, sessions[0].Updated, created, updated)
+	}
+
+	sessions, err = Import([]byte(chatGPTSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1700000000, 5e8); !sessions[0].Created.Equal(want) {
+		t.Errorf("created = %v, want %v", sessions[0].Created, want)
+	}
+}
+
+func TestImportSourceIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"ChatGPT conversation id", chatGPTSample, "conv-1"},
+		{"Open WebUI chat id", openWebUISample, "chat-1"},
+		{"OpenAI id", `{"id": "abc", "messages": [{"role": "user", "content": "hi"}]}`, "abc"},
+	}
+	for _, test := range tests {
+		sessions, err := Import([]byte(test.data))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := sessions[0].SourceID; got != test.want {
+			t.Errorf("%s: source id = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	// Without an id the messages identify the conversation
+	bare := `[{"role": "user", "content": "q"}, {"role": "assistant", "content": "a"}]`
+	first, _ := Import([]byte(bare))
+	again, _ := Import([]byte(bare))
+	other, _ := Import([]byte(strings.Replace(bare, `"a"`, `"b"`, 1)))
+	if first[0].SourceID == "" || first[0].SourceID != again[0].SourceID {
+		t.Errorf("source ids %q and %q should match", first[0].SourceID, again[0].SourceID)
+	}
+	if first[0].SourceID == other[0].SourceID {
+		t.Errorf("different messages got the same source id %q", first[0].SourceID)
+	}
+}
+
+func TestStoreImported(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	sessions, err := Import([]byte(chatGPTSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(sessions[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(&Session{Title: "typed here", Messages: sessions[0].Messages}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := store.Imported()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 || !imported["chatgpt/conv-1"] {
+		t.Errorf("imported = %v, want chatgpt/conv-1 only", imported)
+	}
+}
+
+func TestNewIDIsUnique(t *testing.T) {
+	store := &Store{dir: t.TempDir()}
+	for i := 0; i < 1000; i++ {
+		if err := store.Save(&Session{ID: NewID()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(store.Dir(), "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1000 {
+		t.Errorf("saved %d sessions, want 1000", len(files))
+	}
+}
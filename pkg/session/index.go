@@ -0,0 +1,401 @@
+package session
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Name of the index file inside the session directory. The leading dot keeps
+// List from treating it as a session.
+const indexFile = ".index.gob"
+
+// Default number of results returned by Search
+const defaultSearchLimit = 50
+
+// Number of characters of context shown around a hit in snippets
+const snippetContext = 60
+
+// posting records how often a term occurs in one message of one session
+type posting struct {
+	Session string
+	Message int
+	Count   int
+}
+
+type indexedSession struct {
+	Title    string
+	Updated  time.Time
+	ModTime  time.Time
+	Messages int
+}
+
+// index is an inverted index from terms to the messages containing them,
+// kept on disk so searching thousands of sessions doesn't mean reading them all.
+type index struct {
+	Sessions map[string]indexedSession
+	Postings map[string][]posting
+}
+
+// SearchOptions controls how Search interprets the query
+type SearchOptions struct {
+	// Regex treats the query as a regular expression instead of keywords
+	Regex bool
+	Limit int
+}
+
+// Result is a single matching message
+type Result struct {
+	SessionID string
+	Title     string
+	Updated   time.Time
+	Message   int
+	Snippet   string
+	// Matches are byte ranges of the hits within Snippet
+	Matches [][2]int
+	Score   float64
+}
+
+// tokenize splits text into lowercase words for indexing and keyword queries
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// isWordRune reports whether tokenize keeps r as part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// matcher returns the byte ranges of the hits in a text
+type matcher func(text string) [][]int
+
+// termMatcher finds the terms as whole words. RE2's \b only knows ASCII
+// letters, so word boundaries are checked with tokenize's rules instead.
+func termMatcher(terms []string) matcher {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	// Longer terms go first so one that starts another isn't tried alone
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	return func(text string) [][]int {
+		var hits [][]int
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+			if !isWordRune(before) && !isWordRune(after) {
+				hits = append(hits, loc)
+			}
+		}
+		return hits
+	}
+}
+
+func (s *Store) loadIndex() *index {
+	idx := &index{
+		Sessions: make(map[string]indexedSession),
+		Postings: make(map[string][]posting),
+	}
+	f, err := os.Open(filepath.Join(s.dir, indexFile))
+	if err != nil {
+		return idx
+	}
+	defer f.Close()
+
+	var loaded index
+	if err := gob.NewDecoder(f).Decode(&loaded); err != nil || loaded.Sessions == nil || loaded.Postings == nil {
+		// A corrupt index is rebuilt from scratch
+		return idx
+	}
+	return &loaded
+}
+
+func (s *Store) saveIndex(idx *index) error {
+	tmp, err := os.CreateTemp(s.dir, ".index-*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(idx); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, indexFile))
+}
+
+// updateIndex reindexes sessions whose files changed since the last search
+// and drops deleted ones. It reports whether anything changed.
+func (s *Store) updateIndex(idx *index) (bool, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return false, err
+	}
+
+	seen := make(map[string]bool, len(entries))
+	stale := make(map[string]bool)
+	var changed []*Session
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		seen[id] = true
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if indexed, ok := idx.Sessions[id]; ok && indexed.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+
+		stale[id] = true
+		sess, err := s.Load(id)
+		if err != nil {
+			delete(idx.Sessions, id)
+			continue
+		}
+		idx.Sessions[id] = indexedSession{
+			Title:    sess.Title,
+			Updated:  sess.Updated,
+			ModTime:  info.ModTime(),
+			Messages: len(sess.Messages),
+		}
+		changed = append(changed, sess)
+	}
+	for id := range idx.Sessions {
+		if !seen[id] {
+			stale[id] = true
+			delete(idx.Sessions, id)
+		}
+	}
+	if len(stale) == 0 {
+		return false, nil
+	}
+
+	// Drop old postings for everything that changed in a single pass
+	for term, postings := range idx.Postings {
+		kept := postings[:0]
+		for _, p := range postings {
+			if !stale[p.Session] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.Postings, term)
+		} else {
+			idx.Postings[term] = kept
+		}
+	}
+
+	for _, sess := range changed {
+		for i, message := range sess.Messages {
+			counts := make(map[string]int)
+			for _, term := range tokenize(message.Content) {
+				counts[term]++
+			}
+			for term, count := range counts {
+				idx.Postings[term] = append(idx.Postings[term], posting{Session: sess.ID, Message: i, Count: count})
+			}
+		}
+	}
+	return true, nil
+}
+
+// Search finds messages across all sessions. Keyword queries must match every
+// word and are ranked by TF-IDF; regex queries are ranked by number of hits.
+// Ties go to the most recently updated session.
+func (s *Store) Search(query string, opts SearchOptions) ([]Result, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultSearchLimit
+	}
+
+	idx := s.loadIndex()
+	changed, err := s.updateIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		// Failing to persist the index only makes the next search slower
+		s.saveIndex(idx)
+	}
+
+	var find matcher
+	var results []Result
+	if opts.Regex {
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		find = func(text string) [][]int { return re.FindAllStringIndex(text, -1) }
+		results, err = s.searchRegex(idx, re)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		terms := tokenize(query)
+		if len(terms) == 0 {
+			return nil, nil
+		}
+		find = termMatcher(terms)
+		results = searchTerms(idx, terms)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Updated.After(results[j].Updated)
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	// Only the sessions that made the cut are read for snippets
+	loaded := make(map[string]*Session)
+	for i := range results {
+		r := &results[i]
+		sess, ok := loaded[r.SessionID]
+		if !ok {
+			sess, err = s.Load(r.SessionID)
+			if err != nil {
+				continue
+			}
+			loaded[r.SessionID] = sess
+		}
+		if r.Message < len(sess.Messages) {
+			r.Snippet, r.Matches = snippet(sess.Messages[r.Message].Content, find)
+		}
+	}
+	return results, nil
+}
+
+func searchTerms(idx *index, terms []string) []Result {
+	type key struct {
+		session string
+		message int
+	}
+
+	totalMessages := 0
+	for _, sess := range idx.Sessions {
+		totalMessages += sess.Messages
+	}
+
+	var scores map[key]float64
+	for _, term := range terms {
+		postings := idx.Postings[term]
+		if len(postings) == 0 {
+			return nil
+		}
+		idf := math.Log(1 + float64(totalMessages)/float64(len(postings)))
+
+		next := make(map[key]float64, len(postings))
+		for _, p := range postings {
+			k := key{p.Session, p.Message}
+			score := float64(p.Count) * idf
+			if scores == nil {
+				next[k] = score
+			} else if prev, ok := scores[k]; ok {
+				next[k] = prev + score
+			}
+		}
+		scores = next
+	}
+
+	results := make([]Result, 0, len(scores))
+	for k, score := range scores {
+		sess := idx.Sessions[k.session]
+		results = append(results, Result{
+			SessionID: k.session,
+			Title:     sess.Title,
+			Updated:   sess.Updated,
+			Message:   k.message,
+			Score:     score,
+		})
+	}
+	return results
+}
+
+// searchRegex has to scan message text since the index only knows words
+func (s *Store) searchRegex(idx *index, re *regexp.Regexp) ([]Result, error) {
+	var results []Result
+	for id, indexed := range idx.Sessions {
+		sess, err := s.Load(id)
+		if err != nil {
+			continue
+		}
+		for i, message := range sess.Messages {
+			hits := re.FindAllStringIndex(message.Content, -1)
+			if len(hits) == 0 {
+				continue
+			}
+			results = append(results, Result{
+				SessionID: id,
+				Title:     indexed.Title,
+				Updated:   indexed.Updated,
+				Message:   i,
+				Score:     float64(len(hits)),
+			})
+		}
+	}
+	return results, nil
+}
+
+// snippet returns a single-line excerpt around the first hit
+func snippet(content string, find matcher) (string, [][2]int) {
+	start, end := 0, len(content)
+	if hits := find(content); len(hits) > 0 {
+		start = hits[0][0] - snippetContext
+		end = hits[0][1] + 2*snippetContext
+	} else {
+		end = 3 * snippetContext
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(content) {
+		end = len(content)
+	}
+	// Move inward to rune boundaries
+	for start > 0 && start < len(content) && !isRuneStart(content[start]) {
+		start++
+	}
+	for end < len(content) && !isRuneStart(content[end]) {
+		end--
+	}
+
+	text := strings.Join(strings.Fields(content[start:end]), " ")
+	if start > 0 {
+		text = "…" + text
+	}
+	if end < len(content) {
+		text += "…"
+	}
+
+	var matches [][2]int
+	for _, loc := range find(text) {
+		matches = append(matches, [2]int{loc[0], loc[1]})
+	}
+	return text, matches
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
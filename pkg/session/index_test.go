@@ -0,0 +1,145 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"llm_term/pkg/types"
+)
+
+// testStore saves sessions with the given messages, updated in reverse
+// order of their ids so ties rank the first id highest
+func testStore(t *testing.T, conversations map[string][]string) *Store {
+	t.Helper()
+	store := &Store{dir: t.TempDir()}
+	ids := make([]string, 0, len(conversations))
+	for id := range conversations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	now := time.Now()
+	for i, id := range ids {
+		sess := &Session{ID: id, Title: id, Updated: now.Add(-time.Duration(i) * time.Hour)}
+		for _, content := range conversations[id] {
+			sess.Messages = append(sess.Messages, types.Message{Role: "user", Content: content})
+		}
+		if err := store.Save(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// hits lists the session and message of each result
+func hits(results []Result) string {
+	var list []string
+	for _, r := range results {
+		list = append(list, r.SessionID+"#"+string(rune('0'+r.Message)))
+	}
+	return strings.Join(list, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, World!", "hello world"},
+		{"snake_case and dash-case", "snake_case and dash case"},
+		{"Größe über 3.14", "größe über 3 14"},
+		{"  ", ""},
+	}
+	for _, test := range tests {
+		if got := strings.Join(tokenize(test.text), " "); got != test.want {
+			t.Errorf("tokenize(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	store := testStore(t, map[string][]string{
+		"a": {"the goroutine leaked", "channels and goroutine goroutine"},
+		"b": {"goroutine scheduling in the runtime"},
+		"c": {"nothing relevant", "über straße"},
+	})
+
+	tests := []struct {
+		query string
+		opts  SearchOptions
+		want  string
+	}{
+		// More occurrences rank higher
+		{query: "goroutine", want: "a#1 a#0 b#0"},
+		// Every word has to match
+		{query: "goroutine runtime", want: "b#0"},
+		{query: "GOROUTINE leaked", want: "a#0"},
+		{query: "missing", want: ""},
+		{query: "straße", want: "c#1"},
+		{query: "gorout", want: ""},
+		{query: "go.?routine", opts: SearchOptions{Regex: true}, want: "a#1 a#0 b#0"},
+		{query: "goroutine", opts: SearchOptions{Limit: 1}, want: "a#1"},
+	}
+	for _, test := range tests {
+		results, err := store.Search(test.query, test.opts)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", test.query, err)
+		}
+		if got := hits(results); got != test.want {
+			t.Errorf("Search(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	if _, err := store.Search("(", SearchOptions{Regex: true}); err == nil {
+		t.Error("an invalid pattern should fail")
+	}
+}
+
+func TestSearchAfterDelete(t *testing.T) {
+	store := testStore(t, map[string][]string{
+		"a": {"shared word"},
+		"b": {"shared word"},
+	})
+	if results, _ := store.Search("shared", SearchOptions{}); len(results) != 2 {
+		t.Fatalf("got %d results before deleting, want 2", len(results))
+	}
+	if err := store.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.Search("shared", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hits(results); got != "b#0" {
+		t.Errorf("after deleting a, got %q, want b#0", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		content string
+		terms   string
+		want    string
+	}{
+		{"find the word here", "word", "find the <word> here"},
+		{"swords aren't words", "word", "swords aren't words"},
+		{"Größe und größer", "größe", "<Größe> und größer"},
+		{"naïve über-cool", "über", "naïve <über>-cool"},
+		{"category then cat", "cat category", "<category> then <cat>"},
+		{strings.Repeat("x ", 50) + "needle", "needle", "…" + strings.Repeat("x ", 30) + "<needle>"},
+	}
+	for _, test := range tests {
+		text, matches := snippet(test.content, termMatcher(tokenize(test.terms)))
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			b.WriteString(text[last:m[0]] + "<" + text[m[0]:m[1]] + ">")
+			last = m[1]
+		}
+		b.WriteString(text[last:])
+		if got := b.String(); got != test.want {
+			t.Errorf("snippet(%q, %q) = %q, want %q", test.content, test.terms, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"llm_term/pkg/redact"
+	"llm_term/pkg/types"
+)
+
+// Maximum length of a title derived from the first user message
+const maxTitleLength = 60
+
+// Session is a saved conversation that can be resumed later
+type Session struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title"`
+	Model    string          `json:"model,omitempty"`
+	Source   string          `json:"source,omitempty"`
+	Created  time.Time       `json:"created"`
+	Updated  time.Time       `json:"updated"`
+	Messages []types.Message `json:"messages"`
+	// SourceID identifies an imported conversation in its export, so
+	// importing the same export again doesn't duplicate it
+	SourceID string `json:"source_id,omitempty"`
+	// Pinned sessions are listed first in the sidebar
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// Store keeps sessions as one JSON file per session in a directory
+type Store struct {
+	dir string
+}
+
+func NewStore() (*Store, error) {
+	dir, err := defaultDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating session directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// defaultDir follows the XDG base directory spec, falling back to
+// ~/.local/share when XDG_DATA_HOME is not set.
+func defaultDir() (string, error) {
+	if dir := os.Getenv("LLM_SESSION_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "llm_term", "sessions"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating session directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "llm_term", "sessions"), nil
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save writes the session atomically so a crash never leaves a truncated file
+func (s *Store) Save(sess *Session) error {
+	if sess.ID == "" {
+		sess.ID = NewID()
+	}
+	path, err := s.path(sess.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Keys pasted into the chat or printed by tools stay out of the file
+	data = []byte(redact.String(string(data)))
+
+	tmp, err := os.CreateTemp(s.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *Store) Load(id string) (*Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session %q not found", id)
+		}
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("reading session %q: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// List returns all saved sessions, most recently updated first
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		sess, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			// Skip unreadable files rather than hiding every other session
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	return sessions, nil
+}
+
+// Imported returns the source and source ID of every imported session,
+// joined by a slash, for skipping conversations imported before
+func (s *Store) Imported() (map[string]bool, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	imported := make(map[string]bool)
+	for _, sess := range sessions {
+		if sess.SourceID != "" {
+			imported[sess.Source+"/"+sess.SourceID] = true
+		}
+	}
+	return imported, nil
+}
+
+func (s *Store) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// NewID returns a sortable, unique session id. The random suffix keeps ids
+// apart when many sessions are created in the same second, as an import does.
+func NewID() string {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		panic("session: reading random bytes: " + err.Error())
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// DefaultTitle derives a title from the first user message
+func DefaultTitle(messages []types.Message) string {
+	for _, message := range messages {
+		if message.Role != "user" {
+			continue
+		}
+		title := strings.Join(strings.Fields(message.Content), " ")
+		if title == "" {
+			continue
+		}
+		if runes := []rune(title); len(runes) > maxTitleLength {
+			title = string(runes[:maxTitleLength-1]) + "…"
+		}
+		return title
+	}
+	return "Untitled"
+}
@@ -2,7 +2,6 @@ package system
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -20,13 +19,7 @@ type Metrics struct {
 	stopChan    chan bool
 }
 
-func New() *Metrics {
-	// Initialize with model from environment
-	model := os.Getenv("LLM_MODEL")
-	if model == "" {
-		model = "No model set"
-	}
-
+func New(model string) *Metrics {
 	return &Metrics{
 		stopChan: make(chan bool),
 		Model:    model,
@@ -98,20 +91,20 @@ func (m *Metrics) GetFormattedMetrics(height int) string {
 	// Model and token speed if available
 	if m.Model != "" {
 		result.WriteString("  ") // Add same padding as metrics
-		result.WriteString(fmt.Sprintf("[blue]%s[white] (%.1f tok/s)\n", m.Model, m.TokenSpeed))
+		result.WriteString(fmt.Sprintf("[model]%s[-] (%.1f tok/s)\n", m.Model, m.TokenSpeed))
 		result.WriteString("\n  ") // Add padding for next line
 	}
 	
 	// CPU bar (with padding)
 	result.WriteString("CPU")
-	result.WriteString(fmt.Sprintf(" [red]%s[white]%s",
+	result.WriteString(fmt.Sprintf(" [cpu]%s[-]%s",
 		strings.Repeat(barChar, cpuBars),
 		strings.Repeat(emptyChar, barWidth-cpuBars)))
 	result.WriteString(fmt.Sprintf(" %.0f%%\n", m.CPUUsage))
 
 	// Memory bar (with padding)
 	result.WriteString("  MEM")
-	result.WriteString(fmt.Sprintf(" [yellow]%s[white]%s",
+	result.WriteString(fmt.Sprintf(" [memory]%s[-]%s",
 		strings.Repeat(barChar, memBars),
 		strings.Repeat(emptyChar, barWidth-memBars)))
 	result.WriteString(fmt.Sprintf(" %.0f%%", m.MemoryUsage))
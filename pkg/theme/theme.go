@@ -0,0 +1,306 @@
+// Package theme holds the color palettes of the interface. Text is colored
+// with tags named after what it is rather than its color, such as
+// "[error]" or "[user]", and a palette decides what those look like, so a
+// new palette recolors text already on screen once it's drawn again.
+package theme
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Palette gives a color to each part of the interface. Colors are names
+// such as "yellow", "#rrggbb" hex values or "default" for the terminal's
+// own color. User-defined palettes start from Base and override its colors.
+type Palette struct {
+	Base string `toml:"base"`
+
+	// Colors of the views
+	Background string `toml:"background"`
+	Text       string `toml:"text"`
+	Border     string `toml:"border"`
+	Title      string `toml:"title"`
+	// Accent is the background of buttons and form fields
+	Accent string `toml:"accent"`
+
+	// Colors of text by tag
+	User      string `toml:"user"`
+	Assistant string `toml:"assistant"`
+	Error     string `toml:"error"`
+	Warning   string `toml:"warning"`
+	Success   string `toml:"success"`
+	Muted     string `toml:"muted"`
+	Key       string `toml:"key"`
+	Mode      string `toml:"mode"`
+	Model     string `toml:"model"`
+	CPU       string `toml:"cpu"`
+	Memory    string `toml:"memory"`
+	Code      string `toml:"code"`
+	Match     string `toml:"match"`
+	// Highlight is the background of search matches in the chat
+	Highlight string `toml:"highlight"`
+	// Colors of diffs
+	Added   string `toml:"added"`
+	Removed string `toml:"removed"`
+	Hunk    string `toml:"hunk"`
+}
+
+// Default is the palette used when none is configured
+const Default = "dark"
+
+var builtin = map[string]Palette{
+	"dark": {
+		Background: "black",
+		Text:       "white",
+		Border:     "white",
+		Title:      "white",
+		Accent:     "blue",
+		User:       "yellow",
+		Assistant:  "green",
+		Error:      "red",
+		Warning:    "yellow",
+		Success:    "green",
+		Muted:      "gray",
+		Key:        "green",
+		Mode:       "yellow",
+		Model:      "blue",
+		CPU:        "red",
+		Memory:     "yellow",
+		Code:       "gray",
+		Match:      "yellow",
+		Highlight:  "darkcyan",
+		Added:      "green",
+		Removed:    "red",
+		Hunk:       "aqua",
+	},
+	"light": {
+		Background: "#ffffff",
+		Text:       "#1c1c1c",
+		Border:     "#808080",
+		Title:      "#1c1c1c",
+		Accent:     "#afd7ff",
+		User:       "#af5f00",
+		Assistant:  "#005f00",
+		Error:      "#af0000",
+		Warning:    "#875f00",
+		Success:    "#005f00",
+		Muted:      "#6c6c6c",
+		Key:        "#005f87",
+		Mode:       "#875f00",
+		Model:      "#005fd7",
+		CPU:        "#af0000",
+		Memory:     "#875f00",
+		Code:       "#5f00af",
+		Match:      "#d70000",
+		Highlight:  "#ffd75f",
+		Added:      "#005f00",
+		Removed:    "#af0000",
+		Hunk:       "#005f87",
+	},
+	"high-contrast": {
+		Background: "#000000",
+		Text:       "#ffffff",
+		Border:     "#ffffff",
+		Title:      "#ffff00",
+		Accent:     "#0000ff",
+		User:       "#ffff00",
+		Assistant:  "#00ff00",
+		Error:      "#ff0000",
+		Warning:    "#ffaf00",
+		Success:    "#00ff00",
+		Muted:      "#d0d0d0",
+		Key:        "#00ffff",
+		Mode:       "#ffff00",
+		Model:      "#00ffff",
+		CPU:        "#ff0000",
+		Memory:     "#ffff00",
+		Code:       "#ff00ff",
+		Match:      "#ff00ff",
+		Highlight:  "#005fff",
+		Added:      "#00ff00",
+		Removed:    "#ff0000",
+		Hunk:       "#00ffff",
+	},
+	"solarized": {
+		Background: "#002b36",
+		Text:       "#839496",
+		Border:     "#586e75",
+		Title:      "#93a1a1",
+		Accent:     "#073642",
+		User:       "#b58900",
+		Assistant:  "#859900",
+		Error:      "#dc322f",
+		Warning:    "#cb4b16",
+		Success:    "#859900",
+		Muted:      "#586e75",
+		Key:        "#2aa198",
+		Mode:       "#b58900",
+		Model:      "#268bd2",
+		CPU:        "#dc322f",
+		Memory:     "#b58900",
+		Code:       "#6c71c4",
+		Match:      "#d33682",
+		Highlight:  "#586e75",
+		Added:      "#859900",
+		Removed:    "#dc322f",
+		Hunk:       "#2aa198",
+	},
+}
+
+// NoColor reports whether NO_COLOR asks for text without colors
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// Names lists the built-in palettes and the user-defined ones
+func Names(custom map[string]Palette) []string {
+	var names []string
+	for name := range builtin {
+		names = append(names, name)
+	}
+	for name := range custom {
+		if _, ok := builtin[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve looks up a palette by name, user-defined ones first, and fills
+// in the colors they leave out from their base
+func Resolve(name string, custom map[string]Palette) (Palette, error) {
+	if name == "" {
+		name = Default
+	}
+	p, ok := custom[name]
+	if !ok {
+		p, ok = builtin[name]
+		if !ok {
+			return Palette{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(Names(custom), ", "))
+		}
+		return p, nil
+	}
+
+	baseName := p.Base
+	if baseName == "" {
+		baseName = Default
+	}
+	base, ok := builtin[baseName]
+	if !ok {
+		return Palette{}, fmt.Errorf("theme %s: unknown base %q (expected one of %s)", name, baseName, strings.Join(Names(nil), ", "))
+	}
+	resolved := base
+	for _, c := range p.colors() {
+		if *c.value != "" {
+			*resolved.color(c.name) = *c.value
+		}
+	}
+	if err := resolved.validate(); err != nil {
+		return Palette{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	return resolved, nil
+}
+
+type namedColor struct {
+	name  string
+	value *string
+}
+
+// colors lists the palette's colors by the name they have in config.toml,
+// which is also the tag for the text colors
+func (p *Palette) colors() []namedColor {
+	return []namedColor{
+		{"background", &p.Background}, {"text", &p.Text}, {"border", &p.Border},
+		{"title", &p.Title}, {"accent", &p.Accent},
+		{"user", &p.User}, {"assistant", &p.Assistant}, {"error", &p.Error},
+		{"warning", &p.Warning}, {"success", &p.Success}, {"muted", &p.Muted},
+		{"key", &p.Key}, {"mode", &p.Mode}, {"model", &p.Model}, {"cpu", &p.CPU},
+		{"memory", &p.Memory}, {"code", &p.Code}, {"match", &p.Match},
+		{"highlight", &p.Highlight},
+		{"added", &p.Added}, {"removed", &p.Removed}, {"hunk", &p.Hunk},
+	}
+}
+
+func (p *Palette) color(name string) *string {
+	for _, c := range p.colors() {
+		if c.name == name {
+			return c.value
+		}
+	}
+	return nil
+}
+
+func (p Palette) validate() error {
+	for _, c := range p.colors() {
+		if _, err := parseColor(*c.value); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func parseColor(name string) (tcell.Color, error) {
+	if name == "" || name == "default" {
+		return tcell.ColorDefault, nil
+	}
+	color := tcell.GetColor(name)
+	if color == tcell.ColorDefault {
+		return color, fmt.Errorf("unknown color %q", name)
+	}
+	return color, nil
+}
+
+// viewColors are set on the views; the other colors are tags
+var viewColors = map[string]bool{"background": true, "text": true, "border": true, "title": true, "accent": true}
+
+// current is the palette last applied
+var current = builtin[Default]
+
+// Apply makes a palette current: it defines the tags and sets tview's
+// default styles for views created from now on. Views that already exist
+// need their colors set again and their text redrawn. NO_COLOR leaves
+// everything in the terminal's colors.
+func Apply(p Palette) {
+	current = p
+	for _, c := range p.colors() {
+		if !viewColors[c.name] {
+			tcell.ColorNames[c.name] = Color(c.name)
+		}
+	}
+
+	tview.Styles.PrimitiveBackgroundColor = Color("background")
+	tview.Styles.PrimaryTextColor = Color("text")
+	tview.Styles.BorderColor = Color("border")
+	tview.Styles.GraphicsColor = Color("border")
+	tview.Styles.TitleColor = Color("title")
+	tview.Styles.ContrastBackgroundColor = Color("accent")
+	tview.Styles.MoreContrastBackgroundColor = Color("success")
+	tview.Styles.SecondaryTextColor = Color("mode")
+	tview.Styles.TertiaryTextColor = Color("success")
+	tview.Styles.ContrastSecondaryTextColor = Color("muted")
+	tview.Styles.InverseTextColor = Color("accent")
+}
+
+// Color returns one of the current palette's colors by name
+func Color(name string) tcell.Color {
+	if NoColor() {
+		return tcell.ColorDefault
+	}
+	c, _ := parseColor(*current.color(name))
+	return c
+}
+
+// Selected is the style of the selected item in a list, the text and
+// background colors swapped. Without colors it's shown in reverse video.
+func Selected() tcell.Style {
+	if NoColor() {
+		return tcell.StyleDefault.Reverse(true)
+	}
+	return tcell.StyleDefault.Background(Color("text")).Foreground(Color("background"))
+}
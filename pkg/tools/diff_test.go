@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n, with some of them replaced
+func numbered(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = fmt.Sprint(i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "unchanged",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "a\nb\n",
+			want: "--- /dev/null\n+++ b/f.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "emptied file",
+			old:  "a\n",
+			new:  "",
+			want: "--- a/f.txt\n+++ b/f.txt\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "one line in the middle",
+			old:  numbered(20, nil),
+			new:  numbered(20, map[int]string{10: "ten"}),
+			want: "--- a/f.txt\n+++ b/f.txt\n@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+		{
+			name: "changes far apart get a hunk each",
+			old:  numbered(20, nil),
+			new:  numbered(20, map[int]string{5: "five", 17: "seventeen"}),
+			want: "--- a/f.txt\n+++ b/f.txt\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+				"@@ -14,7 +14,7 @@\n 14\n 15\n 16\n-17\n+seventeen\n 18\n 19\n 20\n",
+		},
+		{
+			name: "changes close together share a hunk",
+			old:  numbered(10, nil),
+			new:  numbered(10, map[int]string{3: "three", 8: "eight"}),
+			want: "--- a/f.txt\n+++ b/f.txt\n" +
+				"@@ -1,10 +1,10 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n",
+		},
+		{
+			name: "insertion and deletion at the ends",
+			old:  "x\ny\n",
+			new:  "y\nz\n",
+			want: "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n-x\n y\n+z\n",
+		},
+		{
+			name: "missing final newline",
+			old:  "a\nb",
+			new:  "a\nc",
+			want: "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := unifiedDiff("f.txt", test.old, test.new); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesIsMinimal(t *testing.T) {
+	a := strings.Split("a b c d e f g", " ")
+	b := strings.Split("a x c d y f g z", " ")
+	changes := 0
+	for _, op := range diffLines(a, b) {
+		if op.kind != ' ' {
+			changes++
+		}
+	}
+	// b and e are replaced and z is added
+	if changes != 5 {
+		t.Errorf("got %d changed lines, want 5", changes)
+	}
+}
@@ -0,0 +1,387 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"llm_term/pkg/chat"
+)
+
+// Limits for the filesystem tools
+const (
+	maxReadSize    = 256 * 1024
+	maxGrepSize    = 1024 * 1024
+	maxGrepMatches = 200
+	maxDirEntries  = 500
+)
+
+// Directories grep doesn't descend into
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Workspace provides read_file, list_dir, grep and write_file tools that
+// can only reach files under Root. Writes need approval through Confirm.
+type Workspace struct {
+	Root    string
+	Confirm ConfirmFunc
+
+	mu sync.Mutex
+	// always holds paths approved with AlwaysAllow this session
+	always map[string]bool
+}
+
+// NewWorkspace is rooted at root, or the current directory if it's empty
+func NewWorkspace(root string, confirm ConfirmFunc) (*Workspace, error) {
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve symlinks so containment checks compare real paths
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, fmt.Errorf("workspace: %w", err)
+	}
+	return &Workspace{Root: root, Confirm: confirm, always: make(map[string]bool)}, nil
+}
+
+// resolve maps a path from the model onto the workspace, rejecting anything
+// that escapes the root directly, through "..", or through a symlink
+func (w *Workspace) resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(w.Root, full)
+	}
+	full = filepath.Clean(full)
+	if !w.contains(full) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+
+	// Check the real location of the nearest existing ancestor, since the
+	// file itself may not exist yet when writing
+	existing := full
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if !w.contains(real) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	return full, nil
+}
+
+func (w *Workspace) contains(path string) bool {
+	rel, err := filepath.Rel(w.Root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relative shows paths to the model relative to the workspace root
+func (w *Workspace) relative(path string) string {
+	if rel, err := filepath.Rel(w.Root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func (w *Workspace) Tools() []chat.Tool {
+	return []chat.Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a text file from the workspace. Paths are relative to the workspace root. Optionally read only a range of lines.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string"},
+					"start_line": {"type": "integer", "description": "First line to return, starting at 1"},
+					"end_line": {"type": "integer", "description": "Last line to return"}
+				},
+				"required": ["path"]
+			}`),
+			Handler: w.readFile,
+		},
+		{
+			Name:        "list_dir",
+			Description: "List the entries of a directory in the workspace. Directories end with a slash.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Directory relative to the workspace root, default ."}
+				}
+			}`),
+			Handler: w.listDir,
+		},
+		{
+			Name:        "grep",
+			Description: "Search text files in the workspace for a regular expression. Returns path:line: text for each match.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"pattern": {"type": "string", "description": "Go regular expression"},
+					"path": {"type": "string", "description": "File or directory to search, default ."},
+					"include": {"type": "string", "description": "Only search files whose name matches this glob, e.g. *.go"}
+				},
+				"required": ["pattern"]
+			}`),
+			Handler: w.grep,
+		},
+		{
+			Name:        "write_file",
+			Description: "Create or overwrite a text file in the workspace with the given content. The user reviews a diff before it is written.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string"},
+					"content": {"type": "string", "description": "The complete new file content"}
+				},
+				"required": ["path", "content"]
+			}`),
+			Handler: w.writeFile,
+		},
+	}
+}
+
+func readText(path string, limit int64) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errors.New("is a directory")
+	}
+	if info.Size() > limit {
+		return "", fmt.Errorf("file is larger than %d KB", limit/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", errors.New("binary file")
+	}
+	return string(data), nil
+}
+
+func (w *Workspace) readFile(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path, err := w.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+	content, err := readText(path, maxReadSize)
+	if err != nil {
+		return "", err
+	}
+	if params.StartLine <= 0 && params.EndLine <= 0 {
+		return content, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	start, end := params.StartLine, params.EndLine
+	if start < 1 {
+		start = 1
+	}
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", fmt.Errorf("line range %d-%d is outside the file (%d lines)", params.StartLine, params.EndLine, len(lines))
+	}
+	return strings.Join(lines[start-1:end], "\n"), nil
+}
+
+func (w *Workspace) listDir(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path, err := w.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for i, entry := range entries {
+		if i == maxDirEntries {
+			fmt.Fprintf(&out, "[%d more entries]\n", len(entries)-maxDirEntries)
+			break
+		}
+		if entry.IsDir() {
+			fmt.Fprintf(&out, "%s/\n", entry.Name())
+			continue
+		}
+		size := int64(0)
+		if info, err := entry.Info(); err == nil {
+			size = info.Size()
+		}
+		fmt.Fprintf(&out, "%s\t%d bytes\n", entry.Name(), size)
+	}
+	if out.Len() == 0 {
+		return "(empty directory)", nil
+	}
+	return out.String(), nil
+}
+
+func (w *Workspace) grep(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+		Include string `json:"include"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	re, err := regexp.Compile(params.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	root, err := w.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	matches := 0
+	errLimit := errors.New("match limit reached")
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() {
+			if path != root && skippedDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks could point outside the workspace
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if params.Include != "" {
+			if ok, _ := filepath.Match(params.Include, entry.Name()); !ok {
+				return nil
+			}
+		}
+		content, err := readText(path, maxGrepSize)
+		if err != nil {
+			return nil
+		}
+		for i, line := range strings.Split(content, "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			fmt.Fprintf(&out, "%s:%d: %s\n", w.relative(path), i+1, line)
+			matches++
+			if matches >= maxGrepMatches {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if err == errLimit {
+		fmt.Fprintf(&out, "[stopped after %d matches]\n", maxGrepMatches)
+	} else if err != nil {
+		return "", err
+	}
+	if matches == 0 {
+		return "no matches", nil
+	}
+	return out.String(), nil
+}
+
+func (w *Workspace) writeFile(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path, err := w.resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	old := ""
+	if _, err := os.Stat(path); err == nil {
+		if old, err = readText(path, maxReadSize); err != nil {
+			return "", fmt.Errorf("refusing to overwrite: %w", err)
+		}
+	}
+	name := w.relative(path)
+	diff := unifiedDiff(name, old, params.Content)
+	if diff == "" {
+		return "file already has this content", nil
+	}
+
+	w.mu.Lock()
+	approved := w.always[name]
+	w.mu.Unlock()
+	if !approved {
+		if w.Confirm == nil {
+			return "", errors.New("writing needs approval but no one can approve it")
+		}
+		decision, _ := w.Confirm(ctx, Approval{Tool: "write_file", Summary: name, Detail: diff})
+		switch decision {
+		case Deny:
+			return "", errors.New("the user declined this change")
+		case AlwaysAllow:
+			w.mu.Lock()
+			w.always[name] = true
+			w.mu.Unlock()
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(params.Content), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %s (%d bytes)", name, len(params.Content)), nil
+}
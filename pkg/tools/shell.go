@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"llm_term/pkg/chat"
+	"llm_term/pkg/config"
+)
+
+// Default limits for commands run by the model
+const (
+	defaultShellTimeout   = 30 * time.Second
+	defaultShellMaxOutput = 16 * 1024
+)
+
+// commandSeparators splits a command line into the commands it runs, so
+// policies can't be bypassed with "ls; rm -rf ~"
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]|\$\(|` + "`")
+
+// uncheckedSyntax matches redirections, process substitution, subshells and
+// parameter expansion, which can write files or run commands the allow-list
+// never sees, so lines using them always need approval
+var uncheckedSyntax = regexp.MustCompile(`[<>()]|\$\{`)
+
+// Shell is the run_shell tool. Commands matching the policy's Allow run
+// without asking, commands matching Deny are always rejected and anything
+// else needs the user's approval through Confirm.
+//
+// Deny patterns are advisory only: they match the command as written, so
+// "rm*" doesn't stop /bin/rm, \rm or command rm. Only the allow-list, which
+// runs nothing unasked that it doesn't match, can be relied on.
+type Shell struct {
+	// Policy returns the current allow- and deny-lists and timeout, so a
+	// config reload applies to the next command
+	Policy    func() config.Shell
+	MaxOutput int
+	Confirm   ConfirmFunc
+
+	mu sync.Mutex
+	// always holds commands approved with AlwaysAllow this session
+	always map[string]bool
+}
+
+// NewShell takes its policy from the [shell] settings that policy returns
+func NewShell(policy func() config.Shell, confirm ConfirmFunc) *Shell {
+	return &Shell{
+		Policy:    policy,
+		MaxOutput: defaultShellMaxOutput,
+		Confirm:   confirm,
+		always:    make(map[string]bool),
+	}
+}
+
+func (s *Shell) Tool() chat.Tool {
+	return chat.Tool{
+		Name:        "run_shell",
+		Description: "Run a shell command on the user's machine and return its exit code, stdout and stderr. The user may review, edit or decline the command.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"command": {"type": "string", "description": "The command line to run with sh -c"}
+			},
+			"required": ["command"]
+		}`),
+		Handler: s.run,
+	}
+}
+
+func (s *Shell) run(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	command := strings.TrimSpace(params.Command)
+	if command == "" {
+		return "", errors.New("command is empty")
+	}
+
+	command, err := s.approve(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	return s.execute(ctx, command)
+}
+
+// approve applies the policy and asks the user if needed, returning the
+// command to run, which the user may have edited
+func (s *Shell) approve(ctx context.Context, command string) (string, error) {
+	if s.denied(command) {
+		return "", errors.New("command is blocked by the deny-list")
+	}
+	if s.allowed(command) {
+		return command, nil
+	}
+	if s.Confirm == nil {
+		return "", errors.New("command needs approval but no one can approve it")
+	}
+
+	decision, edited := s.Confirm(ctx, Approval{Tool: "run_shell", Summary: command, Editable: true})
+	if decision == Deny {
+		return "", errors.New("the user declined to run this command")
+	}
+	edited = strings.TrimSpace(edited)
+	if edited == "" {
+		return "", errors.New("command is empty")
+	}
+	// An edit must not sneak past the deny-list
+	if s.denied(edited) {
+		return "", errors.New("command is blocked by the deny-list")
+	}
+	if decision == AlwaysAllow {
+		s.mu.Lock()
+		s.always[edited] = true
+		s.mu.Unlock()
+	}
+	return edited, nil
+}
+
+func splitCommands(command string) []string {
+	var commands []string
+	for _, part := range commandSeparators.Split(command, -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			commands = append(commands, part)
+		}
+	}
+	return commands
+}
+
+func matchesPattern(command, pattern string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(command, prefix)
+	}
+	return command == pattern
+}
+
+func matchesAny(command string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(command, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// denied reports whether any command in the line is on the deny-list
+func (s *Shell) denied(command string) bool {
+	for _, part := range splitCommands(command) {
+		if matchesAny(part, s.Policy().Deny) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether the whole line was always-allowed or every
+// command in it is on the allow-list and none of them redirects or
+// substitutes anything
+func (s *Shell) allowed(command string) bool {
+	s.mu.Lock()
+	always := s.always[command]
+	s.mu.Unlock()
+	if always {
+		return true
+	}
+	if uncheckedSyntax.MatchString(command) {
+		return false
+	}
+
+	parts := splitCommands(command)
+	allow := s.Policy().Allow
+	if len(parts) == 0 || len(allow) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		if !matchesAny(part, allow) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Shell) execute(ctx context.Context, command string) (string, error) {
+	timeout := defaultShellTimeout
+	if seconds := s.Policy().Timeout; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{max: s.MaxOutput}
+	stderr := &limitedBuffer{max: s.MaxOutput}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait forever on pipes held open by background children
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", fmt.Errorf("command timed out after %s\nstdout:\n%s\nstderr:\n%s", timeout, stdout, stderr)
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return "", err
+	}
+
+	return fmt.Sprintf("$ %s\nexit code: %d\nstdout:\n%s\nstderr:\n%s", command, exitCode, stdout, stderr), nil
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decision is the user's answer to an approval request
+type Decision int
+
+const (
+	Deny Decision = iota
+	Approve
+	// AlwaysAllow approves this action and skips asking again for the rest of the session
+	AlwaysAllow
+)
+
+// Approval asks the user before a tool does something with side effects
+type Approval struct {
+	Tool string
+	// Summary is the action in one line, e.g. the shell command
+	Summary string
+	// Detail is optional longer context such as a diff
+	Detail string
+	// Editable lets the user change Summary before approving
+	Editable bool
+}
+
+// ConfirmFunc blocks until the user decides or ctx is cancelled. It returns
+// the summary, which may have been edited for editable approvals.
+type ConfirmFunc func(ctx context.Context, approval Approval) (Decision, string)
+
+// limitedBuffer keeps the first max bytes written to it and counts the rest
+type limitedBuffer struct {
+	max       int
+	buf       []byte
+	truncated int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.max - len(b.buf)
+	if room > len(p) {
+		room = len(p)
+	}
+	if room > 0 {
+		b.buf = append(b.buf, p[:room]...)
+	}
+	b.truncated += len(p) - room
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated == 0 {
+		return string(b.buf)
+	}
+	return fmt.Sprintf("%s\n[output truncated, %d more bytes]", b.buf, b.truncated)
+}
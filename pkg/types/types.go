@@ -1,11 +1,19 @@
 package types
 
+import "encoding/json"
+
 type Mode int
 
 const (
 	NormalMode Mode = iota
 	InputMode
 	ResponseMode
+	// PromptMode reuses the input field for a one-line prompt such as a search
+	PromptMode
+	// VisualMode selects lines or messages in the chat view for yanking
+	VisualMode
+	// SidebarMode moves through the saved sessions in the sidebar
+	SidebarMode
 )
 
 type KeyBinding struct {
@@ -16,12 +24,50 @@ type KeyBinding struct {
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Thinking is the reasoning a model produced before its answer
+	Thinking string `json:"thinking,omitempty"`
+	// Images are base64-encoded, as Ollama expects them for vision models
+	Images []string `json:"images,omitempty"`
+	// ToolCalls are the functions an assistant message asks to run
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName and ToolCallID tie a "tool" message to the call it answers;
+	// Ollama matches on the name, OpenAI on the id
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Tool describes a function the model may call, in the shape both Ollama and
+// OpenAI accept
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
 }
 
 type ChatRequest struct {
 	Model       string    `json:"model"`
 	Temperature float64   `json:"temperature"`
 	Messages    []Message `json:"messages"`
+	Tools       []Tool    `json:"tools,omitempty"`
+	// Format asks for JSON output: "json" or a JSON schema, as in Ollama's API
+	Format      json.RawMessage `json:"format,omitempty"`
+	// Options are sampling parameters like temperature and num_ctx
+	Options     map[string]any  `json:"options,omitempty"`
 }
 
 type ChatResponse struct {
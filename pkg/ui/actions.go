@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"llm_term/pkg/types"
+)
+
+// actions lists what each mode can do, with the keys bound by default.
+// The keybind strip shows them in this order.
+func (ui *UI) actions() map[types.Mode][]action {
+	scrolling := []action{
+		{name: "down", description: "scroll down", keys: []string{"j"}, run: func() { ui.scrollBy(1) }},
+		{name: "up", description: "scroll up", keys: []string{"k"}, run: func() { ui.scrollBy(-1) }},
+		{name: "top", description: "scroll to top", keys: []string{"g g"}, run: ui.scrollToTop},
+		{name: "bottom", description: "scroll to bottom", keys: []string{"G"}, run: ui.scrollToBottom},
+		{name: "half_page_down", description: "scroll down half page", keys: []string{"Ctrl+D"}, run: func() { ui.scrollHalfPage(1) }},
+		{name: "half_page_up", description: "scroll up half page", keys: []string{"Ctrl+U"}, run: func() { ui.scrollHalfPage(-1) }},
+	}
+	quit := action{name: "quit", description: "quit", keys: []string{"q"}, run: ui.app.Stop}
+	help := action{name: "help", description: "help", keys: []string{"F1", "g ?"}, run: ui.showHelp}
+	palette := action{name: "palette", description: "command palette", keys: []string{"Ctrl+P"}, run: ui.showPalette}
+
+	normal := []action{
+		help,
+		palette,
+		quit,
+		{name: "input", description: "enter input mode", keys: []string{"i"}, run: func() {
+			ui.setMode(types.InputMode)
+			ui.autoScroll = true // Reset auto-scroll when entering input mode
+		}},
+		{name: "sessions", description: "search sessions", keys: []string{"s"}, run: ui.showSessionSearch},
+		{name: "sidebar", description: "session sidebar", keys: []string{"Ctrl+B"}, run: ui.showSidebar},
+	}
+	normal = append(normal, scrolling...)
+	normal = append(normal, []action{
+		{name: "search_forward", description: "search forward", keys: []string{"/"}, run: func() {
+			ui.startPrompt("/", "SEARCH", func(text string) { ui.searchChat(text, false) })
+		}},
+		{name: "search_backward", description: "search backward", keys: []string{"?"}, run: func() {
+			ui.startPrompt("?", "SEARCH", func(text string) { ui.searchChat(text, true) })
+		}},
+		{name: "next_match", description: "next match", keys: []string{"n"}, run: func() { ui.nextMatch(false) }},
+		{name: "previous_match", description: "previous match", keys: []string{"N"}, run: func() { ui.nextMatch(true) }},
+		{name: "select_lines", description: "select lines", keys: []string{"v"}, run: func() { ui.startVisual(false) }},
+		{name: "select_messages", description: "select messages", keys: []string{"V"}, run: func() { ui.startVisual(true) }},
+		{name: "copy_code", description: "copy code block", keys: []string{"y c"}, run: func() {
+			ui.startPrompt("code block [N] [> file]: ", "COPY CODE", ui.copyCodeBlock)
+		}},
+		{name: "next_block", description: "select next block", keys: []string{"Tab"}, run: func() { ui.selectFold(1) }},
+		{name: "previous_block", description: "select previous block", keys: []string{"Shift+Tab"}, run: func() { ui.selectFold(-1) }},
+		{name: "toggle_block", description: "expand/collapse block", keys: []string{"o"}, run: ui.toggleSelectedFold},
+		{name: "toggle_thinking", description: "show/hide thinking", keys: []string{"t"}, run: ui.toggleThinking},
+		{name: "clear_search", description: "clear search", keys: []string{"Esc"}, run: ui.clearSearch},
+		{name: "toggle_keybinds", description: "compact/full key strip", run: func() { ui.setCompactKeybinds(!ui.compactKeybinds) }},
+	}...)
+
+	response := append([]action{help, palette, quit}, scrolling...)
+	response = append(response, action{name: "cancel", description: "cancel response", keys: []string{"Ctrl+C"}, run: func() {
+		if ui.isAIResponding {
+			ui.chat.Cancel()
+		}
+	}})
+
+	return map[types.Mode][]action{
+		types.NormalMode: normal,
+		types.InputMode: {
+			{name: "help", description: "help", keys: []string{"F1"}, run: ui.showHelp},
+			palette,
+			{name: "normal", description: "enter normal mode", keys: []string{"Esc"}, run: func() { ui.setMode(types.NormalMode) }},
+			{name: "send", description: "send message", keys: []string{"Enter"}, run: ui.send},
+		},
+		types.ResponseMode: response,
+		types.PromptMode: {
+			{name: "confirm", description: "confirm", keys: []string{"Enter"}, run: ui.confirmPrompt},
+			{name: "cancel", description: "cancel", keys: []string{"Esc"}, run: func() {
+				back := ui.prompt.back
+				ui.prompt = nil
+				ui.setMode(back)
+			}},
+		},
+		types.VisualMode: {
+			{name: "down", description: "extend down", keys: []string{"j"}, run: func() { ui.moveVisual(1) }},
+			{name: "up", description: "extend up", keys: []string{"k"}, run: func() { ui.moveVisual(-1) }},
+			{name: "top", description: "extend to top", keys: []string{"g g"}, run: func() { ui.moveVisual(-len(ui.visual.lineStarts)) }},
+			{name: "bottom", description: "extend to bottom", keys: []string{"G"}, run: func() { ui.moveVisual(len(ui.visual.lineStarts)) }},
+			{name: "yank", description: "yank to clipboard", keys: []string{"y"}, run: ui.yankVisual},
+			{name: "select_lines", description: "toggle line selection", keys: []string{"v"}, run: func() { ui.switchVisual(false) }},
+			{name: "select_messages", description: "toggle message selection", keys: []string{"V"}, run: func() { ui.switchVisual(true) }},
+			{name: "cancel", description: "cancel", keys: []string{"Esc"}, run: func() { ui.setMode(types.NormalMode) }},
+			quit,
+		},
+		types.SidebarMode: {
+			help,
+			palette,
+			{name: "down", description: "next session", keys: []string{"j"}, run: func() { ui.moveSidebar(1) }},
+			{name: "up", description: "previous session", keys: []string{"k"}, run: func() { ui.moveSidebar(-1) }},
+			{name: "top", description: "first session", keys: []string{"g g"}, run: func() { ui.moveSidebar(-len(ui.sidebarSessions)) }},
+			{name: "bottom", description: "last session", keys: []string{"G"}, run: func() { ui.moveSidebar(len(ui.sidebarSessions)) }},
+			{name: "open", description: "open session", keys: []string{"Enter"}, run: ui.openSelectedSession},
+			{name: "new", description: "new session", keys: []string{"n"}, run: func() {
+				ui.newSession()
+				ui.setMode(types.InputMode)
+			}},
+			{name: "rename", description: "rename session", keys: []string{"r"}, run: ui.renameSession},
+			{name: "delete", description: "delete session", keys: []string{"d"}, run: ui.deleteSession},
+			{name: "pin", description: "pin/unpin session", keys: []string{"p"}, run: ui.togglePin},
+			{name: "normal", description: "back to the chat", keys: []string{"Esc"}, run: func() { ui.setMode(types.NormalMode) }},
+			{name: "hide", description: "hide sidebar", keys: []string{"Ctrl+B"}, run: ui.hideSidebar},
+			quit,
+		},
+	}
+}
+
+// Hints for keys the input field handles itself, shown after the bindings
+var keyHints = map[types.Mode][]types.KeyBinding{
+	types.InputMode: {{Key: "@path", Description: "attach file (Tab completes)"}},
+}
+
+func (ui *UI) scrollBy(lines int) {
+	row, _ := ui.chatView.GetScrollOffset()
+	ui.chatView.ScrollTo(max(row+lines, 0), 0)
+	ui.autoScroll = false
+}
+
+func (ui *UI) scrollHalfPage(direction int) {
+	_, _, _, height := ui.chatView.GetInnerRect()
+	ui.scrollBy(direction * height / 2)
+}
+
+func (ui *UI) scrollToTop() {
+	ui.chatView.ScrollToBeginning()
+	ui.autoScroll = false
+}
+
+func (ui *UI) scrollToBottom() {
+	ui.chatView.ScrollToEnd()
+	ui.autoScroll = true
+}
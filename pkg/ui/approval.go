@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/tools"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+type approvalAnswer struct {
+	decision tools.Decision
+	summary  string
+}
+
+// confirm asks the user to approve a tool action in a modal. It is called
+// from the streaming goroutine and blocks until the user answers or the
+// response is cancelled.
+func (ui *UI) confirm(ctx context.Context, approval tools.Approval) (tools.Decision, string) {
+	answers := make(chan approvalAnswer, 1)
+	ui.app.QueueUpdateDraw(func() {
+		ui.showApproval(approval, func(answer approvalAnswer) {
+			answers <- answer
+		})
+	})
+
+	select {
+	case answer := <-answers:
+		return answer.decision, answer.summary
+	case <-ctx.Done():
+		ui.app.QueueUpdateDraw(func() {
+			ui.closeApproval()
+		})
+		return tools.Deny, approval.Summary
+	}
+}
+
+func (ui *UI) closeApproval() {
+	if ui.pages.HasPage("approval") {
+		ui.pages.RemovePage("approval")
+		ui.updateModeState()
+	}
+}
+
+func (ui *UI) showApproval(approval tools.Approval, answer func(approvalAnswer)) {
+	details := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(true)
+	fmt.Fprintf(details, "The model wants to use [warning]%s[-]:\n\n  %s\n", approval.Tool, tview.Escape(approval.Summary))
+	if approval.Detail != "" {
+		fmt.Fprintf(details, "\n%s\n", colorDiff(approval.Detail))
+	}
+
+	form := tview.NewForm().SetButtonsAlign(tview.AlignCenter)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(details, 0, 1, false).
+		AddItem(form, 3, 0, true)
+	layout.SetBorder(true).
+		SetTitle("Approve tool call").
+		SetTitleAlign(tview.AlignLeft)
+
+	done := func(decision tools.Decision, summary string) {
+		ui.closeApproval()
+		answer(approvalAnswer{decision: decision, summary: summary})
+	}
+
+	form.AddButton("Approve", func() { done(tools.Approve, approval.Summary) })
+	form.AddButton("Deny", func() { done(tools.Deny, approval.Summary) })
+	if approval.Editable {
+		form.AddButton("Edit", func() {
+			// Swap the buttons for an editable copy of the command
+			form.Clear(true)
+			form.AddInputField("", approval.Summary, 0, nil, nil)
+			form.AddButton("Run", func() {
+				edited := form.GetFormItem(0).(*tview.InputField).GetText()
+				done(tools.Approve, edited)
+			})
+			form.AddButton("Cancel", func() { done(tools.Deny, approval.Summary) })
+			layout.ResizeItem(form, 5, 0)
+			ui.app.SetFocus(form)
+		})
+	}
+	form.AddButton("Always allow", func() { done(tools.AlwaysAllow, approval.Summary) })
+	form.SetCancelFunc(func() { done(tools.Deny, approval.Summary) })
+
+	// Let long details scroll while the buttons keep focus
+	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyPgDn, tcell.KeyPgUp, tcell.KeyUp, tcell.KeyDown:
+			details.InputHandler()(event, nil)
+			return nil
+		}
+		return event
+	})
+
+	ui.pages.AddPage("approval", modal(layout, 100, 24), true, true)
+	ui.app.SetFocus(form)
+}
+
+// colorDiff escapes detail text and colors it if it is a unified diff
+func colorDiff(detail string) string {
+	lines := strings.Split(detail, "\n")
+	for i, line := range lines {
+		escaped := tview.Escape(line)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = "[::b]" + escaped + "[::-]"
+		case strings.HasPrefix(line, "+"):
+			lines[i] = "[added]" + escaped + "[-]"
+		case strings.HasPrefix(line, "-"):
+			lines[i] = "[removed]" + escaped + "[-]"
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = "[hunk]" + escaped + "[-]"
+		default:
+			lines[i] = escaped
+		}
+	}
+	return strings.Join(lines, "\n")
+}
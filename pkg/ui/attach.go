@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/types"
+
+	"github.com/rivo/tview"
+)
+
+// setupAttachments completes @path references in the input field and
+// previews what will be attached in the chat title
+func (ui *UI) setupAttachments() {
+	ui.inputField.SetAutocompleteFunc(func(text string) []string {
+		if ui.currentMode != types.InputMode {
+			return nil
+		}
+		if matches := ui.completeCommand(text); matches != nil {
+			return matches
+		}
+		return attach.Complete(text)
+	})
+	ui.inputField.SetAutocompletedFunc(func(text string, index, source int) bool {
+		if source == tview.AutocompletedNavigate {
+			return false
+		}
+		ui.inputField.SetText(text)
+		// Keep completing into directories
+		return !strings.HasSuffix(text, "/")
+	})
+	ui.inputField.SetChangedFunc(func(text string) {
+		ui.updateAttachmentPreview(text)
+	})
+}
+
+func (ui *UI) updateAttachmentPreview(text string) {
+	if ui.currentMode != types.InputMode || !strings.Contains(text, "@") {
+		ui.chatView.SetTitle("Chat")
+		return
+	}
+	// Only stat the files, since this runs on every keystroke; they're read
+	// when the prompt is sent
+	attachments := attach.Stat(text)
+	if len(attachments) == 0 {
+		ui.chatView.SetTitle("Chat")
+		return
+	}
+
+	previews := make([]string, len(attachments))
+	for i, a := range attachments {
+		if a.Err != nil {
+			previews[i] = fmt.Sprintf("%s (%v)", a.Path, a.Err)
+		} else {
+			previews[i] = fmt.Sprintf("%s %s", a.Path, attach.FormatSize(a.Size))
+		}
+	}
+	ui.chatView.SetTitle("Chat · attaching " + strings.Join(previews, ", "))
+}
+
+// attachmentChips is the compact line shown under a prompt in place of the
+// inlined file contents
+func attachmentChips(attachments []attach.Attachment) string {
+	chips := make([]string, len(attachments))
+	for i, a := range attachments {
+		if a.Err != nil {
+			chips[i] = fmt.Sprintf("[error]⊘ %s: %v[-]", tview.Escape(a.Path), a.Err)
+			continue
+		}
+		icon := "📎"
+		if a.IsImage() {
+			icon = "🖼"
+		}
+		chips[i] = fmt.Sprintf("[::r] %s %s %s [::-]", icon, tview.Escape(a.Path), attach.FormatSize(a.Size))
+	}
+	return "  " + strings.Join(chips, " ")
+}
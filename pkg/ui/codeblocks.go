@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"llm_term/pkg/clipboard"
+	"llm_term/pkg/types"
+
+	"github.com/rivo/tview"
+)
+
+// codeBlock is a fenced code block from an AI answer, kept verbatim so it
+// can be copied without tview tags or fences
+type codeBlock struct {
+	lang    string
+	content string
+	// line is the line of the answer with the opening fence
+	line int
+}
+
+// extractCodeBlocks finds ``` and ~~~ fenced blocks in markdown. An
+// unterminated block runs to the end of the text.
+func extractCodeBlocks(markdown string) []codeBlock {
+	var blocks []codeBlock
+	var current *codeBlock
+	var fence string
+	var body []string
+
+	for i, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current == nil {
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				fence = trimmed[:3]
+				for len(fence) < len(trimmed) && trimmed[len(fence)] == fence[0] {
+					fence += fence[:1]
+				}
+				current = &codeBlock{lang: strings.TrimSpace(trimmed[len(fence):]), line: i}
+				body = body[:0]
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			current.content = strings.Join(body, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	if current != nil {
+		current.content = strings.Join(body, "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// renderCodeBlockMarkers numbers the code blocks of an answer just written
+// to the chat, putting [N] on the opening fence of each. An answer that
+// wasn't shown as written, such as JSON that was indented, gets a line
+// listing its blocks instead.
+func (ui *UI) renderCodeBlockMarkers(answer string) {
+	blocks := extractCodeBlocks(answer)
+	if len(blocks) == 0 {
+		return
+	}
+	first := len(ui.codeBlocks) + 1
+	ui.codeBlocks = append(ui.codeBlocks, blocks...)
+
+	text := ui.chatView.GetText(false)
+	if start := strings.LastIndex(text, answer); start >= 0 {
+		lines := strings.Split(answer, "\n")
+		for i, block := range blocks {
+			lines[block.line] += fmt.Sprintf("  [code][%d[]%s[-]", first+i, ui.copyHint(first+i))
+		}
+		row, col := ui.chatView.GetScrollOffset()
+		ui.chatView.SetText(text[:start] + strings.Join(lines, "\n") + text[start+len(answer):])
+		ui.chatView.ScrollTo(row, col)
+		return
+	}
+
+	markers := make([]string, len(blocks))
+	for i, block := range blocks {
+		lang := block.lang
+		if lang == "" {
+			lang = "text"
+		}
+		lines := strings.Count(block.content, "\n") + 1
+		markers[i] = fmt.Sprintf("[%d[] %s (%d lines)", first+i, lang, lines)
+	}
+	fmt.Fprintf(ui.chatView, "[code]  code: %s%s[-]\n", strings.Join(markers, " · "), ui.copyHint(0))
+}
+
+// copyHint names the keys that copy a block, such as " · yc 2 to copy"
+func (ui *UI) copyHint(n int) string {
+	keys := ui.keys.keysFor(types.NormalMode, "copy_code")
+	if keys == "" {
+		return ""
+	}
+	if n > 0 {
+		keys += " " + strconv.Itoa(n)
+	}
+	return tview.Escape(" · " + keys + " to copy")
+}
+
+// copyCodeBlock handles the answer to the yc prompt: "N" copies block N,
+// "N path" (or "N > path") writes it to a file, and no number means the last block.
+func (ui *UI) copyCodeBlock(text string) {
+	if len(ui.codeBlocks) == 0 {
+		ui.flash = "no code blocks"
+		return
+	}
+
+	n := len(ui.codeBlocks)
+	fields := strings.Fields(text)
+	if len(fields) > 0 {
+		if i, err := strconv.Atoi(fields[0]); err == nil {
+			n = i
+			fields = fields[1:]
+		}
+	}
+	if n < 1 || n > len(ui.codeBlocks) {
+		ui.flash = fmt.Sprintf("[error]no code block %d", n)
+		return
+	}
+	block := ui.codeBlocks[n-1]
+
+	if len(fields) > 0 && fields[0] == ">" {
+		fields = fields[1:]
+	}
+	if path := strings.Join(fields, " "); path != "" {
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		content := block.content
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			ui.flash = fmt.Sprintf("[error]%v", err)
+			return
+		}
+		ui.flash = fmt.Sprintf("wrote block %d", n)
+		return
+	}
+
+	if err := clipboard.Copy(block.content); err != nil {
+		ui.flash = fmt.Sprintf("[error]%v", err)
+		return
+	}
+	ui.flash = fmt.Sprintf("copied block %d", n)
+}
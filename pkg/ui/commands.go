@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// slashCommand is typed in the input field instead of a prompt, e.g.
+// "/rag add ~/src/project"
+type slashCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string)
+}
+
+func (ui *UI) setupCommands() {
+	ui.commands = []slashCommand{
+		{
+			name:        "json",
+			usage:       "/json [schema.json | {inline schema} | off]",
+			description: "ask for JSON on the next prompt, validated against a schema",
+			run:         ui.jsonCommand,
+		},
+		{
+			name:        "pull",
+			usage:       "/pull [model]",
+			description: "download a model to the Ollama server, the configured model by default",
+			run:         ui.pullCommand,
+		},
+		{
+			name:        "rag",
+			usage:       "/rag add <dir> | cancel | remove <dir> | list | clear",
+			description: "manage directories used as context for prompts",
+			run:         ui.ragCommand,
+		},
+		{
+			name:        "theme",
+			usage:       "/theme [name]",
+			description: "list the color themes, or switch to one for this session",
+			run:         ui.themeCommand,
+		},
+	}
+	sort.Slice(ui.commands, func(i, j int) bool {
+		return ui.commands[i].name < ui.commands[j].name
+	})
+}
+
+// runSlashCommand runs text if it starts with a known command. Anything else
+// starting with a slash, like a path, is sent to the model as usual.
+func (ui *UI) runSlashCommand(text string) bool {
+	if !strings.HasPrefix(text, "/") {
+		return false
+	}
+	fields := strings.Fields(text[1:])
+	if len(fields) == 0 {
+		return false
+	}
+	for _, command := range ui.commands {
+		if command.name == fields[0] {
+			fmt.Fprintf(ui.chatView, "[muted]> %s[-]\n", tview.Escape(text))
+			ui.inputField.SetText("")
+			command.run(fields[1:])
+			return true
+		}
+	}
+	return false
+}
+
+// commandOutput prints a command's result in the chat. Commands that work in
+// the background must call it through QueueUpdateDraw.
+func (ui *UI) commandOutput(format string, args ...any) {
+	fmt.Fprintf(ui.chatView, "[muted]%s[-]\n", tview.Escape(fmt.Sprintf(format, args...)))
+	ui.chatView.ScrollToEnd()
+}
+
+func (ui *UI) commandError(format string, args ...any) {
+	fmt.Fprintf(ui.chatView, "[error]%s[-]\n", tview.Escape(fmt.Sprintf(format, args...)))
+	ui.chatView.ScrollToEnd()
+}
+
+// completeCommand offers command names while the first word is typed
+func (ui *UI) completeCommand(text string) []string {
+	if !strings.HasPrefix(text, "/") || strings.Contains(text, " ") {
+		return nil
+	}
+	var matches []string
+	for _, command := range ui.commands {
+		if strings.HasPrefix("/"+command.name, text) {
+			matches = append(matches, "/"+command.name+" ")
+		}
+	}
+	return matches
+}
@@ -0,0 +1,137 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/types"
+
+	"github.com/rivo/tview"
+)
+
+// Maximum length of tool arguments shown in a collapsed tool block
+const maxFoldArgsLength = 60
+
+// fold is a collapsible block in the chat view, such as a tool call and its
+// result. It lives in the buffer as a region so it can be found and redrawn.
+type fold struct {
+	id      string
+	summary string
+	body    string
+	open    bool
+	// thinking marks a model's reasoning, which can be shown or hidden all at once
+	thinking bool
+}
+
+// render returns the fold's region without a trailing newline
+func (f *fold) render() string {
+	if !f.open || f.body == "" {
+		return fmt.Sprintf(`["%s"][muted]▸ %s[-][""]`, f.id, tview.Escape(f.summary))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `["%s"][muted]▾ %s`, f.id, tview.Escape(f.summary))
+	for _, line := range strings.Split(strings.TrimRight(f.body, "\n"), "\n") {
+		b.WriteString("\n    ")
+		b.WriteString(tview.Escape(line))
+	}
+	b.WriteString(`[-][""]`)
+	return b.String()
+}
+
+// addFold appends a collapsible block on its own line. It must run on the UI
+// goroutine.
+func (ui *UI) addFold(summary, body string, open bool) *fold {
+	f := &fold{
+		id:      fmt.Sprintf("fold-%d", len(ui.folds)),
+		summary: summary,
+		body:    body,
+		open:    open,
+	}
+	ui.folds = append(ui.folds, f)
+
+	if text := ui.chatView.GetText(false); text != "" && !strings.HasSuffix(text, "\n") {
+		fmt.Fprint(ui.chatView, "\n")
+	}
+	fmt.Fprintf(ui.chatView, "%s\n", f.render())
+	return f
+}
+
+// toggleFold expands or collapses a fold in place, keeping the scroll position
+func (ui *UI) toggleFold(f *fold) {
+	ui.clearSearch()
+	text := ui.chatView.GetText(false)
+	start := strings.Index(text, fmt.Sprintf(`["%s"]`, f.id))
+	if start < 0 {
+		return
+	}
+	end := strings.Index(text[start:], `[""]`)
+	if end < 0 {
+		return
+	}
+	end += start + len(`[""]`)
+
+	f.open = !f.open
+	row, col := ui.chatView.GetScrollOffset()
+	ui.autoScroll = false
+	ui.chatView.SetText(text[:start] + f.render() + text[end:])
+	ui.chatView.ScrollTo(row, col)
+	ui.chatView.Highlight(f.id)
+}
+
+// selectFold moves the fold selection by step, wrapping around, and scrolls
+// the selected fold into view
+func (ui *UI) selectFold(step int) {
+	if len(ui.folds) == 0 {
+		return
+	}
+	n := len(ui.folds)
+	if ui.selectedFold < 0 || ui.selectedFold >= n {
+		// Start from the most recent fold
+		ui.selectedFold = n - 1
+	} else {
+		ui.selectedFold = ((ui.selectedFold+step)%n + n) % n
+	}
+
+	ui.clearSearch()
+	ui.autoScroll = false
+	ui.chatView.Highlight(ui.folds[ui.selectedFold].id).ScrollToHighlight()
+}
+
+// toggleSelectedFold toggles the selected fold, or the latest one if none is selected
+func (ui *UI) toggleSelectedFold() {
+	if len(ui.folds) == 0 {
+		return
+	}
+	if ui.selectedFold < 0 || ui.selectedFold >= len(ui.folds) {
+		ui.selectedFold = len(ui.folds) - 1
+	}
+	ui.toggleFold(ui.folds[ui.selectedFold])
+}
+
+// addToolFold renders a tool call and its result as a collapsed block
+func (ui *UI) addToolFold(call types.ToolCall, result string, err error) {
+	body := result
+	if err != nil {
+		body = fmt.Sprintf("error: %v", err)
+	}
+	ui.addFold(toolSummary(call, err), body, false)
+}
+
+func toolSummary(call types.ToolCall, err error) string {
+	var compact bytes.Buffer
+	args := string(call.Function.Arguments)
+	if json.Compact(&compact, call.Function.Arguments) == nil {
+		args = compact.String()
+	}
+	if runes := []rune(args); len(runes) > maxFoldArgsLength {
+		args = string(runes[:maxFoldArgsLength-1]) + "…"
+	}
+
+	status := "✓"
+	if err != nil {
+		status = "✗"
+	}
+	return fmt.Sprintf("%s %s %s", status, call.Function.Name, args)
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/chat"
+	"llm_term/pkg/mcp"
+	"llm_term/pkg/theme"
+	"llm_term/pkg/types"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// helpSection is a titled group of rows in the help overlay
+type helpSection struct {
+	title string
+	rows  []helpRow
+}
+
+type helpRow struct {
+	key         string
+	description string
+	detail      string
+}
+
+// helpSections lists the keys of every mode, the slash commands and the
+// files llm_term reads and writes
+func (ui *UI) helpSections() []helpSection {
+	var sections []helpSection
+	for _, mode := range modeOrder {
+		section := helpSection{title: strings.ToUpper(modeNames[mode][:1]) + modeNames[mode][1:] + " mode"}
+		for _, a := range ui.keys.actions[mode] {
+			keys := ui.keys.keysFor(mode, a.name)
+			if keys == "" {
+				keys = "unbound"
+			}
+			section.rows = append(section.rows, helpRow{keys, a.description, a.name})
+		}
+		for _, hint := range keyHints[mode] {
+			section.rows = append(section.rows, helpRow{hint.Key, hint.Description, ""})
+		}
+		sections = append(sections, section)
+	}
+
+	commands := helpSection{title: "Slash commands"}
+	for _, command := range ui.commands {
+		commands.rows = append(commands.rows, helpRow{"/" + command.name, command.description, command.usage})
+	}
+	sections = append(sections, commands)
+
+	files := helpSection{title: "Files"}
+	files.rows = append(files.rows, helpRow{"config", ui.chat.Config().Path, "settings, profiles, themes and keybinds"})
+	if path, err := mcp.ConfigPath(); err == nil {
+		files.rows = append(files.rows, helpRow{"MCP", path, "MCP servers"})
+	}
+	if path, err := chat.CredentialsPath(); err == nil {
+		files.rows = append(files.rows, helpRow{"credentials", path, "API keys by endpoint"})
+	}
+	if ui.store != nil {
+		files.rows = append(files.rows, helpRow{"sessions", ui.store.Dir(), "saved conversations"})
+	}
+	if ui.rag != nil {
+		files.rows = append(files.rows, helpRow{"RAG index", ui.rag.Path(), "indexed directories"})
+	}
+	files.rows = append(files.rows, helpRow{".env", ".env", "environment variables, read from the working directory"})
+	return append(sections, files)
+}
+
+// helpText writes the sections, keeping the rows that contain the query
+func helpText(sections []helpSection, query string) string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	width := 0
+	for _, section := range sections {
+		for _, row := range section.rows {
+			width = max(width, len(row.key))
+		}
+	}
+
+	var b strings.Builder
+	for _, section := range sections {
+		var rows []helpRow
+		for _, row := range section.rows {
+			text := strings.ToLower(section.title + " " + row.key + " " + row.description + " " + row.detail)
+			if strings.Contains(text, query) {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		descriptionWidth := 0
+		for _, row := range rows {
+			descriptionWidth = max(descriptionWidth, len(row.description))
+		}
+		fmt.Fprintf(&b, "[::b]%s[::-]\n", tview.Escape(section.title))
+		for _, row := range rows {
+			fmt.Fprintf(&b, "  [key]%s[-] %s  [muted]%s[-]\n",
+				tview.Escape(fmt.Sprintf("%-*s", width, row.key)),
+				tview.Escape(fmt.Sprintf("%-*s", descriptionWidth, row.description)),
+				tview.Escape(row.detail))
+		}
+		b.WriteByte('\n')
+	}
+	if b.Len() == 0 {
+		return "[muted]Nothing matches[-]"
+	}
+	return b.String()
+}
+
+// showHelp opens an overlay with every key binding, slash command and
+// config file, narrowed down by what's typed
+func (ui *UI) showHelp() {
+	sections := ui.helpSections()
+	// The mode the overlay was opened from closes it with the same key
+	mode := ui.currentMode
+
+	input := tview.NewInputField().
+		SetLabel("Search: ").
+		SetFieldWidth(0).
+		SetFieldBackgroundColor(theme.Color("background"))
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	status := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[muted]Up/Down/PgUp/PgDn: scroll  Esc: close[-]")
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(view, 0, 1, false).
+		AddItem(status, 1, 0, false)
+	layout.SetBorder(true).
+		SetTitle("Help").
+		SetTitleAlign(tview.AlignLeft)
+
+	closeHelp := func() {
+		ui.pages.RemovePage("help")
+		ui.restoreFocus()
+	}
+
+	input.SetChangedFunc(func(text string) {
+		view.SetText(helpText(sections, text))
+		view.ScrollToBeginning()
+	})
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			closeHelp()
+		}
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			view.InputHandler()(event, func(tview.Primitive) {})
+			return nil
+		}
+		if event.Key() != tcell.KeyRune && ui.keys.matches(mode, "help", event) {
+			closeHelp()
+			return nil
+		}
+		return event
+	})
+
+	view.SetText(helpText(sections, ""))
+	ui.pages.AddPage("help", modal(layout, 110, 34), true, true)
+	ui.app.SetFocus(input)
+}
+
+// setCompactKeybinds collapses the keybind strip into a single line, or
+// brings back the full one
+func (ui *UI) setCompactKeybinds(compact bool) {
+	ui.compactKeybinds = compact
+	if ui.frame != nil {
+		ui.frame.ResizeItem(ui.keybindView, ui.keybindHeight(), 0)
+	}
+	ui.updateKeybindDisplay()
+}
+
+func (ui *UI) keybindHeight() int {
+	if ui.compactKeybinds {
+		return 1
+	}
+	return 6
+}
+
+// statusLine writes the bindings on one line, as much as fits
+func statusLine(binds []types.KeyBinding) string {
+	parts := make([]string, len(binds))
+	for i, bind := range binds {
+		parts[i] = fmt.Sprintf("[key]%s[-] %s", tview.Escape(bind.Key), tview.Escape(bind.Description))
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/chat"
+)
+
+// Re-prompts after an invalid JSON answer unless json_retries says otherwise
+const defaultJSONRetries = 2
+
+// jsonCommand asks for JSON on the next prompt, optionally matching a
+// schema given inline or as a file
+func (ui *UI) jsonCommand(args []string) {
+	arg := strings.TrimSpace(strings.Join(args, " "))
+	if arg == "off" {
+		ui.chat.SetJSONMode(nil)
+		ui.commandOutput("JSON mode off")
+		return
+	}
+
+	retries := defaultJSONRetries
+	if n := ui.chat.Config().JSONRetries; n != nil {
+		retries = *n
+	}
+	mode := &chat.JSONMode{Retries: retries}
+
+	if arg != "" {
+		schema := []byte(arg)
+		source := "the inline schema"
+		if !strings.HasPrefix(arg, "{") {
+			data, err := os.ReadFile(attach.ExpandHome(arg))
+			if err != nil {
+				ui.commandError("/json: %v", err)
+				return
+			}
+			schema, source = data, arg
+		}
+		var parsed map[string]any
+		if err := json.Unmarshal(schema, &parsed); err != nil {
+			ui.commandError("/json: %s is not a JSON schema: %v", source, err)
+			return
+		}
+		// Compact rather than re-encode so property order is kept
+		var compact bytes.Buffer
+		json.Compact(&compact, schema)
+		mode.Schema = compact.Bytes()
+		ui.chat.SetJSONMode(mode)
+		ui.commandOutput("The next prompt asks for JSON matching %s", source)
+		return
+	}
+
+	ui.chat.SetJSONMode(mode)
+	ui.commandOutput("The next prompt asks for JSON")
+}
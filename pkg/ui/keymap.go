@@ -0,0 +1,357 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/types"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// How long a chord such as gg waits for its next key
+const chordTimeout = 500 * time.Millisecond
+
+// Mode names as written in the keybinds section of config.toml
+var modeNames = map[types.Mode]string{
+	types.NormalMode:   "normal",
+	types.InputMode:    "input",
+	types.ResponseMode: "response",
+	types.PromptMode:   "prompt",
+	types.VisualMode:   "visual",
+	types.SidebarMode:  "sidebar",
+}
+
+// Modes in the order they're documented
+var modeOrder = []types.Mode{types.NormalMode, types.InputMode, types.ResponseMode, types.PromptMode, types.VisualMode, types.SidebarMode}
+
+// action is something a key can do in a mode. The same name can mean
+// different things in different modes, e.g. down scrolls in normal mode and
+// extends the selection in visual mode.
+type action struct {
+	name        string
+	description string
+	// keys are the default bindings
+	keys []string
+	run  func()
+}
+
+// keyPress is one key of a binding
+type keyPress struct {
+	key tcell.Key
+	ch  rune
+	alt bool
+}
+
+func keyPressOf(event *tcell.EventKey) keyPress {
+	k := keyPress{key: event.Key(), alt: event.Modifiers()&tcell.ModAlt != 0}
+	if k.key == tcell.KeyRune {
+		k.ch = event.Rune()
+	}
+	return k
+}
+
+// binding is a key sequence bound to an action
+type binding struct {
+	keys   []keyPress
+	action *action
+}
+
+// keymap dispatches keys to actions, waiting for the rest of a chord when
+// a key starts one
+type keymap struct {
+	actions  map[types.Mode][]*action
+	bindings map[types.Mode][]binding
+	// bound holds each action's key sequences as written in config.toml
+	bound     map[types.Mode]map[string][]string
+	pending   []keyPress
+	pendingAt time.Time
+}
+
+// newKeymap binds the actions to their default keys, overridden by the
+// keybinds from config.toml
+func newKeymap(actions map[types.Mode][]action, overrides config.Keybinds) (*keymap, error) {
+	km := &keymap{
+		actions:  make(map[types.Mode][]*action),
+		bindings: make(map[types.Mode][]binding),
+		bound:    make(map[types.Mode]map[string][]string),
+	}
+	for mode, list := range actions {
+		km.bound[mode] = make(map[string][]string)
+		for i := range list {
+			km.actions[mode] = append(km.actions[mode], &list[i])
+			km.bound[mode][list[i].name] = list[i].keys
+		}
+	}
+
+	// Names qualified by mode win over plain ones, whatever the order
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.Count(names[i], ".") < strings.Count(names[j], ".")
+	})
+	for _, name := range names {
+		if err := km.override(name, overrides[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, mode := range modeOrder {
+		for _, a := range km.actions[mode] {
+			for _, written := range km.bound[mode][a.name] {
+				keys, err := parseKeys(written)
+				if err != nil {
+					return nil, fmt.Errorf("keybinds.%s.%s: %w", modeNames[mode], a.name, err)
+				}
+				// Text modes need printable keys for typing
+				if (mode == types.InputMode || mode == types.PromptMode) && keys[0].key == tcell.KeyRune && !keys[0].alt {
+					return nil, fmt.Errorf("keybinds.%s.%s: %q would stop %s being typed", modeNames[mode], a.name, written, formatKey(keys[0]))
+				}
+				km.bindings[mode] = append(km.bindings[mode], binding{keys: keys, action: a})
+			}
+		}
+		if err := km.checkConflicts(mode); err != nil {
+			return nil, err
+		}
+	}
+	return km, nil
+}
+
+func (km *keymap) override(name string, keys []string) error {
+	modeName, actionName, qualified := strings.Cut(name, ".")
+	if !qualified {
+		found := false
+		for mode := range km.actions {
+			if km.find(mode, name) != nil {
+				km.bound[mode][name] = keys
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("keybinds.%s: unknown action", name)
+		}
+		return nil
+	}
+
+	for mode, n := range modeNames {
+		if n != modeName {
+			continue
+		}
+		if km.find(mode, actionName) == nil {
+			return fmt.Errorf("keybinds.%s: %s mode has no action %q", name, modeName, actionName)
+		}
+		km.bound[mode][actionName] = keys
+		return nil
+	}
+	return fmt.Errorf("keybinds.%s: unknown mode %q", name, modeName)
+}
+
+func (km *keymap) find(mode types.Mode, name string) *action {
+	for _, a := range km.actions[mode] {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// checkConflicts rejects a key sequence bound twice, or one that starts
+// another and would keep it from ever being reached
+func (km *keymap) checkConflicts(mode types.Mode) error {
+	bindings := km.bindings[mode]
+	for i, a := range bindings {
+		for _, b := range bindings[i+1:] {
+			if isPrefix(a.keys, b.keys) || isPrefix(b.keys, a.keys) {
+				short, long := a, b
+				if len(b.keys) < len(a.keys) {
+					short, long = b, a
+				}
+				if len(short.keys) == len(long.keys) {
+					return fmt.Errorf("keybinds: %s is bound to both %s and %s in %s mode",
+						formatKeys(short.keys), short.action.name, long.action.name, modeNames[mode])
+				}
+				return fmt.Errorf("keybinds: %s (%s) starts %s (%s) in %s mode",
+					formatKeys(short.keys), short.action.name, formatKeys(long.keys), long.action.name, modeNames[mode])
+			}
+		}
+	}
+	return nil
+}
+
+func isPrefix(prefix, keys []keyPress) bool {
+	return len(prefix) <= len(keys) && slices.Equal(prefix, keys[:len(prefix)])
+}
+
+// dispatch runs the action bound to a key, or notes the key when it starts
+// a chord. It reports whether the key was used.
+func (km *keymap) dispatch(mode types.Mode, event *tcell.EventKey) bool {
+	if len(km.pending) > 0 && time.Since(km.pendingAt) > chordTimeout {
+		km.pending = nil
+	}
+	sequence := append(slices.Clone(km.pending), keyPressOf(event))
+
+	chord := false
+	for _, b := range km.bindings[mode] {
+		if slices.Equal(b.keys, sequence) {
+			km.pending = nil
+			b.action.run()
+			return true
+		}
+		if isPrefix(sequence, b.keys) {
+			chord = true
+		}
+	}
+	if chord {
+		km.pending, km.pendingAt = sequence, time.Now()
+		return true
+	}
+	// A key that doesn't continue the chord counts on its own
+	if len(km.pending) > 0 {
+		km.pending = nil
+		return km.dispatch(mode, event)
+	}
+	return false
+}
+
+// matches reports whether a key on its own runs an action
+func (km *keymap) matches(mode types.Mode, name string, event *tcell.EventKey) bool {
+	key := keyPressOf(event)
+	for _, b := range km.bindings[mode] {
+		if b.action.name == name && len(b.keys) == 1 && b.keys[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// reset drops a half-typed chord
+func (km *keymap) reset() {
+	km.pending = nil
+}
+
+// help lists the actions of a mode with their keys, leaving out unbound ones
+func (km *keymap) help(mode types.Mode) []types.KeyBinding {
+	var binds []types.KeyBinding
+	for _, a := range km.actions[mode] {
+		if keys := km.keysFor(mode, a.name); keys != "" {
+			binds = append(binds, types.KeyBinding{Key: keys, Description: a.description})
+		}
+	}
+	return binds
+}
+
+// keysFor returns an action's key sequences as they're displayed, such as
+// "j/Down"
+func (km *keymap) keysFor(mode types.Mode, name string) string {
+	var keys []string
+	for _, b := range km.bindings[mode] {
+		if b.action.name == name {
+			keys = append(keys, formatKeys(b.keys))
+		}
+	}
+	return strings.Join(keys, "/")
+}
+
+// Names of special keys beyond tcell's own, all lower case
+var keyAliases = map[string]tcell.Key{
+	"escape":    tcell.KeyEsc,
+	"return":    tcell.KeyEnter,
+	"shift+tab": tcell.KeyBacktab,
+	"pageup":    tcell.KeyPgUp,
+	"pagedown":  tcell.KeyPgDn,
+	"del":       tcell.KeyDelete,
+}
+
+// parseKeys reads a key sequence such as "Ctrl+D", "g g" or "gg". Keys are
+// separated by spaces, but a lower-case word that isn't the name of a key
+// stands for its characters typed one after another.
+func parseKeys(text string) ([]keyPress, error) {
+	var keys []keyPress
+	for _, word := range strings.Fields(text) {
+		key, err := parseKey(word)
+		if err == nil {
+			keys = append(keys, key)
+			continue
+		}
+		// Anything else is most likely a misspelt key name
+		if strings.ContainsFunc(word, func(r rune) bool { return r == '+' || unicode.IsUpper(r) }) {
+			return nil, err
+		}
+		for _, ch := range word {
+			keys = append(keys, keyPress{key: tcell.KeyRune, ch: ch})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	return keys, nil
+}
+
+func parseKey(word string) (keyPress, error) {
+	if utf8.RuneCountInString(word) == 1 {
+		ch, _ := utf8.DecodeRuneInString(word)
+		return keyPress{key: tcell.KeyRune, ch: ch}, nil
+	}
+	lower := strings.ToLower(word)
+	if rest, ok := strings.CutPrefix(lower, "alt+"); ok {
+		key, err := parseKey(word[len(word)-len(rest):])
+		key.alt = true
+		return key, err
+	}
+	if lower == "space" {
+		return keyPress{key: tcell.KeyRune, ch: ' '}, nil
+	}
+	if rest, ok := strings.CutPrefix(lower, "ctrl+"); ok && len(rest) == 1 && rest[0] >= 'a' && rest[0] <= 'z' {
+		return keyPress{key: tcell.KeyCtrlA + tcell.Key(rest[0]-'a')}, nil
+	}
+	if key, ok := keyAliases[lower]; ok {
+		return keyPress{key: key}, nil
+	}
+	for key, name := range tcell.KeyNames {
+		if strings.ToLower(strings.ReplaceAll(name, "-", "+")) == lower {
+			return keyPress{key: key}, nil
+		}
+	}
+	return keyPress{}, fmt.Errorf("unknown key %q", word)
+}
+
+// formatKeys writes a sequence the way the keybind strip shows it: typed
+// characters run together, as in gg, and named keys are spaced out
+func formatKeys(keys []keyPress) string {
+	var b strings.Builder
+	for i, key := range keys {
+		named := key.key != tcell.KeyRune || key.alt || key.ch == ' '
+		if i > 0 && (named || keys[i-1].key != tcell.KeyRune || keys[i-1].alt) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(formatKey(key))
+	}
+	return b.String()
+}
+
+func formatKey(key keyPress) string {
+	name := string(key.ch)
+	switch {
+	case key.key == tcell.KeyRune && key.ch == ' ':
+		name = "Space"
+	case key.key == tcell.KeyBacktab:
+		name = "Shift+Tab"
+	case key.key >= tcell.KeyCtrlA && key.key <= tcell.KeyCtrlZ && key.key != tcell.KeyTab &&
+		key.key != tcell.KeyEnter && key.key != tcell.KeyBackspace:
+		name = fmt.Sprintf("Ctrl+%c", 'A'+rune(key.key-tcell.KeyCtrlA))
+	case key.key != tcell.KeyRune:
+		name = strings.ReplaceAll(tcell.KeyNames[key.key], "-", "+")
+	}
+	if key.alt {
+		return "Alt+" + name
+	}
+	return name
+}
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/types"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		err  string
+	}{
+		{text: "j", want: "j"},
+		{text: "gg", want: "gg"},
+		{text: "g g", want: "gg"},
+		{text: "g ?", want: "g?"},
+		{text: "Ctrl+D", want: "Ctrl+D"},
+		{text: "ctrl+d", want: "Ctrl+D"},
+		{text: "Alt+Enter", want: "Alt+Enter"},
+		{text: "alt+x", want: "Alt+x"},
+		{text: "Space", want: "Space"},
+		{text: "Escape", want: "Esc"},
+		{text: "Shift+Tab", want: "Shift+Tab"},
+		{text: "PageDown", want: "PgDn"},
+		{text: "F1", want: "F1"},
+		{text: "y c", want: "yc"},
+		{text: "Ctrl+W j", want: "Ctrl+W j"},
+		{text: "", err: "empty key sequence"},
+		{text: "Ctrl+Shift+X", err: `unknown key "Ctrl+Shift+X"`},
+		{text: "Enterr", err: `unknown key "Enterr"`},
+	}
+	for _, test := range tests {
+		keys, err := parseKeys(test.text)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("parseKeys(%q) error = %v, want %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseKeys(%q): %v", test.text, err)
+			continue
+		}
+		if got := formatKeys(keys); got != test.want {
+			t.Errorf("parseKeys(%q) = %s, want %s", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseKeysEvents(t *testing.T) {
+	tests := []struct {
+		text  string
+		event *tcell.EventKey
+	}{
+		{"j", tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone)},
+		{"Ctrl+D", tcell.NewEventKey(tcell.KeyCtrlD, 0, tcell.ModCtrl)},
+		{"Alt+Enter", tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModAlt)},
+		{"Enter", tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)},
+		{"Space", tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone)},
+	}
+	for _, test := range tests {
+		keys, err := parseKeys(test.text)
+		if err != nil {
+			t.Fatalf("parseKeys(%q): %v", test.text, err)
+		}
+		if len(keys) != 1 || keys[0] != keyPressOf(test.event) {
+			t.Errorf("parseKeys(%q) = %+v, want %+v", test.text, keys, keyPressOf(test.event))
+		}
+	}
+}
+
+func testActions() map[types.Mode][]action {
+	noop := func() {}
+	return map[types.Mode][]action{
+		types.NormalMode: {
+			{name: "down", keys: []string{"j"}, run: noop},
+			{name: "top", keys: []string{"g g"}, run: noop},
+			{name: "copy", keys: []string{"y y"}, run: noop},
+		},
+		types.InputMode: {
+			{name: "send", keys: []string{"Enter"}, run: noop},
+			{name: "down", keys: []string{"Ctrl+N"}, run: noop},
+		},
+	}
+}
+
+func TestKeymapConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides config.Keybinds
+		err       string
+	}{
+		{name: "defaults"},
+		{name: "rebinding", overrides: config.Keybinds{"top": {"Home"}, "normal.copy": {"y"}}},
+		{name: "unbinding", overrides: config.Keybinds{"normal.down": {}}},
+		{
+			name:      "same key twice",
+			overrides: config.Keybinds{"normal.copy": {"j"}},
+			err:       "keybinds: j is bound to both down and copy in normal mode",
+		},
+		{
+			name:      "key starting a chord",
+			overrides: config.Keybinds{"normal.down": {"g"}},
+			err:       "keybinds: g (down) starts gg (top) in normal mode",
+		},
+		{
+			name:      "plain name applies to every mode",
+			overrides: config.Keybinds{"down": {"Enter"}},
+			err:       "keybinds: Enter is bound to both send and down in input mode",
+		},
+		{
+			name:      "typing key in a text mode",
+			overrides: config.Keybinds{"input.down": {"x"}},
+			err:       `keybinds.input.down: "x" would stop x being typed`,
+		},
+		{
+			name:      "unknown action",
+			overrides: config.Keybinds{"jump": {"J"}},
+			err:       "keybinds.jump: unknown action",
+		},
+		{
+			name:      "unknown mode",
+			overrides: config.Keybinds{"insert.down": {"J"}},
+			err:       `keybinds.insert.down: unknown mode "insert"`,
+		},
+		{
+			name:      "bad key",
+			overrides: config.Keybinds{"normal.down": {"Ctrl+Foo"}},
+			err:       `keybinds.normal.down: unknown key "Ctrl+Foo"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newKeymap(testActions(), test.overrides)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.err != "" && (err == nil || err.Error() != test.err):
+				t.Errorf("error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestKeymapDispatch(t *testing.T) {
+	var ran []string
+	record := func(name string) func() { return func() { ran = append(ran, name) } }
+	km, err := newKeymap(map[types.Mode][]action{
+		types.NormalMode: {
+			{name: "down", keys: []string{"j"}, run: record("down")},
+			{name: "top", keys: []string{"g g"}, run: record("top")},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	press := func(ch rune) bool {
+		return km.dispatch(types.NormalMode, tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModNone))
+	}
+	if !press('g') || len(ran) != 0 {
+		t.Fatalf("g should wait for the rest of the chord, ran %v", ran)
+	}
+	if !press('g') {
+		t.Fatal("gg wasn't used")
+	}
+	// A key that doesn't continue a chord runs on its own
+	press('g')
+	press('j')
+	if press('x') {
+		t.Error("x isn't bound but was used")
+	}
+	if got := strings.Join(ran, ","); got != "top,down" {
+		t.Errorf("ran %s, want top,down", got)
+	}
+}
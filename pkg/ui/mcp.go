@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"llm_term/pkg/mcp"
+
+	"github.com/rivo/tview"
+)
+
+// How long a server gets to start and answer the handshake
+const mcpStartTimeout = 30 * time.Second
+
+// startMCP launches the configured MCP servers in the background and
+// registers their tools as each one comes up
+func (ui *UI) startMCP() {
+	config, err := mcp.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(ui.chatView, "[error]MCP config: %s[-]\n", tview.Escape(err.Error()))
+		return
+	}
+
+	names := make([]string, 0, len(config.Servers))
+	for name, server := range config.Servers {
+		if !server.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		go ui.connectMCP(name, config.Servers[name])
+	}
+}
+
+func (ui *UI) connectMCP(name string, server mcp.ServerConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+
+	report := func(format string, args ...any) {
+		ui.app.QueueUpdateDraw(func() {
+			fmt.Fprintf(ui.chatView, format, args...)
+		})
+	}
+
+	client, err := mcp.Start(ctx, name, server)
+	if err != nil {
+		report("[error]MCP %s[-]\n", tview.Escape(err.Error()))
+		return
+	}
+
+	ui.mcpMu.Lock()
+	if ui.mcpClosed {
+		ui.mcpMu.Unlock()
+		client.Close()
+		return
+	}
+	ui.mcpClients = append(ui.mcpClients, client)
+	ui.mcpMu.Unlock()
+
+	var tools, resources, prompts int
+	if client.Supports("tools") {
+		chatTools, err := client.ChatTools(ctx)
+		if err != nil {
+			report("[error]MCP %s: listing tools: %s[-]\n", name, tview.Escape(err.Error()))
+		}
+		for _, tool := range chatTools {
+			ui.chat.RegisterTool(tool)
+		}
+		tools = len(chatTools)
+	}
+	if client.Supports("resources") {
+		list, _ := client.ListResources(ctx)
+		resources = len(list)
+	}
+	if client.Supports("prompts") {
+		list, _ := client.ListPrompts(ctx)
+		prompts = len(list)
+	}
+	report("[muted]MCP %s: %d tools, %d resources, %d prompts[-]\n", tview.Escape(name), tools, resources, prompts)
+}
+
+// stopMCP shuts down every server when the app exits
+func (ui *UI) stopMCP() {
+	ui.mcpMu.Lock()
+	clients := ui.mcpClients
+	ui.mcpClients = nil
+	ui.mcpClosed = true
+	ui.mcpMu.Unlock()
+
+	for _, client := range clients {
+		client.Close()
+	}
+}
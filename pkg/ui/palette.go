@@ -0,0 +1,214 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"llm_term/pkg/theme"
+	"llm_term/pkg/types"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// paletteEntry is an action or slash command offered by the command palette
+type paletteEntry struct {
+	label  string
+	keys   string
+	detail string
+	run    func()
+}
+
+// paletteEntries lists what can be done from the current mode: its actions,
+// normal mode's too when typing since Esc is a key away, and the slash
+// commands unless a response is coming in
+func (ui *UI) paletteEntries() []paletteEntry {
+	mode := ui.currentMode
+	modes := []types.Mode{mode}
+	if mode == types.InputMode {
+		modes = append(modes, types.NormalMode)
+	}
+
+	var entries []paletteEntry
+	seen := make(map[string]bool)
+	for _, m := range modes {
+		for _, a := range ui.keys.actions[m] {
+			// Entering input mode from it would only clear what's typed
+			if a.name == "palette" || seen[a.name] || (m != mode && a.name == "input") {
+				continue
+			}
+			seen[a.name] = true
+			run := a.run
+			if m != mode {
+				m, a := m, a
+				run = func() {
+					ui.setMode(m)
+					a.run()
+				}
+			}
+			entries = append(entries, paletteEntry{
+				label:  a.description,
+				keys:   ui.keys.keysFor(m, a.name),
+				detail: modeNames[m] + " mode",
+				run:    run,
+			})
+		}
+	}
+
+	if ui.isAIResponding {
+		return entries
+	}
+	for _, command := range ui.commands {
+		command := command
+		entries = append(entries, paletteEntry{
+			label:  "/" + command.name,
+			detail: command.description,
+			// Commands take arguments, so they're left in the input field
+			// to finish and send
+			run: func() {
+				ui.setMode(types.InputMode)
+				ui.inputField.SetText("/" + command.name + " ")
+			},
+		})
+	}
+	return entries
+}
+
+// showPalette opens an overlay that finds actions and slash commands by
+// fuzzy search and runs the one picked
+func (ui *UI) showPalette() {
+	entries := ui.paletteEntries()
+
+	input := tview.NewInputField().
+		SetLabel("> ").
+		SetFieldWidth(0).
+		SetFieldBackgroundColor(theme.Color("background"))
+	list := tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true).
+		SetSelectedStyle(theme.Selected())
+	status := tview.NewTextView().
+		SetDynamicColors(true)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false).
+		AddItem(status, 1, 0, false)
+	layout.SetBorder(true).
+		SetTitle("Command palette").
+		SetTitleAlign(tview.AlignLeft)
+
+	closePalette := func() {
+		ui.pages.RemovePage("palette")
+		ui.restoreFocus()
+	}
+
+	var shown []paletteEntry
+	filter := func(query string) {
+		shown = filterPalette(entries, query)
+		list.Clear()
+		for _, e := range shown {
+			list.AddItem(fmt.Sprintf("%s [key]%s[-] [muted]%s[-]",
+				tview.Escape(fmt.Sprintf("%-26s", e.label)),
+				tview.Escape(fmt.Sprintf("%-12s", e.keys)),
+				tview.Escape(e.detail)), "", 0, nil)
+		}
+		status.SetText(fmt.Sprintf("[muted]%d of %d  Enter: run  Up/Down: select  Esc: close[-]", len(shown), len(entries)))
+	}
+	run := func(index int) {
+		if index < 0 || index >= len(shown) {
+			return
+		}
+		closePalette()
+		shown[index].run()
+	}
+	move := func(step int) {
+		if count := list.GetItemCount(); count > 0 {
+			list.SetCurrentItem((list.GetCurrentItem() + step + count) % count)
+		}
+	}
+
+	input.SetChangedFunc(filter)
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			run(list.GetCurrentItem())
+		case tcell.KeyEscape:
+			closePalette()
+		}
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyCtrlP, tcell.KeyBacktab:
+			move(-1)
+			return nil
+		case tcell.KeyDown, tcell.KeyCtrlN, tcell.KeyTab:
+			move(1)
+			return nil
+		}
+		return event
+	})
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		run(index)
+	})
+
+	filter("")
+	ui.pages.AddPage("palette", modal(layout, 90, 20), true, true)
+	ui.app.SetFocus(input)
+}
+
+// filterPalette keeps the entries matching a query, best matches first
+func filterPalette(entries []paletteEntry, query string) []paletteEntry {
+	type scored struct {
+		entry paletteEntry
+		score int
+	}
+	var matches []scored
+	for _, e := range entries {
+		if score, ok := fuzzyScore(query, e.label+" "+e.detail); ok {
+			matches = append(matches, scored{e, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	filtered := make([]paletteEntry, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.entry
+	}
+	return filtered
+}
+
+// fuzzyScore reports whether the characters of a query appear in text in
+// order, ignoring case and spaces. Characters that follow one another or
+// start a word score higher, so "stt" ranks "scroll to top" above
+// "select next block".
+func fuzzyScore(query, text string) (int, bool) {
+	runes := []rune(strings.ToLower(text))
+	score, next, previous := 0, 0, -2
+	for _, q := range strings.ToLower(query) {
+		if unicode.IsSpace(q) {
+			continue
+		}
+		i := next
+		for i < len(runes) && runes[i] != q {
+			i++
+		}
+		if i == len(runes) {
+			return 0, false
+		}
+		switch {
+		case i == previous+1:
+			score += 3
+		case i == 0 || !unicode.IsLetter(runes[i-1]):
+			score += 2
+		default:
+			score++
+		}
+		previous, next = i, i+1
+	}
+	return score, true
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/chat"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Width of the download progress bar in cells
+const pullBarWidth = 50
+
+// How often download progress is redrawn while bytes arrive
+const pullRedrawInterval = 100 * time.Millisecond
+
+// pullCommand downloads a model, the configured one unless another is named
+func (ui *UI) pullCommand(args []string) {
+	model := ui.chat.Config().Model
+	if len(args) == 1 {
+		model = args[0]
+	}
+	if len(args) > 1 || model == "" {
+		ui.commandOutput("usage: /pull [model]")
+		return
+	}
+	if !ui.chat.CanPull() {
+		ui.commandError("/pull needs an Ollama endpoint")
+		return
+	}
+
+	go func() {
+		err := ui.pullModel(context.Background(), model)
+		ui.app.QueueUpdateDraw(func() {
+			switch {
+			case errors.Is(err, context.Canceled):
+				ui.commandOutput("Cancelled pulling %s", model)
+			case err != nil:
+				ui.commandError("/pull %s: %v", model, err)
+			default:
+				ui.commandOutput("Pulled %s", model)
+			}
+		})
+	}()
+}
+
+// offerPull asks whether to download a model the server doesn't have, and
+// pulls it if the user agrees. It is called from the streaming goroutine
+// and reports whether the model is now available.
+func (ui *UI) offerPull(ctx context.Context, model string) bool {
+	answers := make(chan bool, 1)
+	ui.app.QueueUpdateDraw(func() {
+		ui.showPullOffer(model, func(pull bool) {
+			answers <- pull
+		})
+	})
+
+	select {
+	case pull := <-answers:
+		if !pull {
+			return false
+		}
+	case <-ctx.Done():
+		ui.app.QueueUpdateDraw(func() {
+			ui.closePull()
+		})
+		return false
+	}
+
+	err := ui.pullModel(ctx, model)
+	ui.app.QueueUpdateDraw(func() {
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(ui.chatView, "[error]Pulling %s failed: %s[-]\n", tview.Escape(model), tview.Escape(err.Error()))
+			}
+			return
+		}
+		fmt.Fprintf(ui.chatView, "[muted]Pulled %s, sending the prompt again[-]\n", tview.Escape(model))
+	})
+	return err == nil
+}
+
+func (ui *UI) closePull() {
+	if ui.pages.HasPage("pull") {
+		ui.pages.RemovePage("pull")
+		ui.updateModeState()
+	}
+}
+
+func (ui *UI) showPullOffer(model string, answer func(bool)) {
+	details := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+	fmt.Fprintf(details, "The server doesn't have [model]%s[-].\n\nDownload it now? Models are often several GB.", tview.Escape(model))
+
+	form := tview.NewForm().SetButtonsAlign(tview.AlignCenter)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(details, 0, 1, false).
+		AddItem(form, 3, 0, true)
+	layout.SetBorder(true).
+		SetTitle("Model not found").
+		SetTitleAlign(tview.AlignLeft)
+
+	done := func(pull bool) {
+		// The progress view replaces the question, so only close on refusal
+		if !pull {
+			ui.closePull()
+		}
+		answer(pull)
+	}
+	form.AddButton("Pull", func() { done(true) })
+	form.AddButton("Cancel", func() { done(false) })
+	form.SetCancelFunc(func() { done(false) })
+
+	ui.pages.AddPage("pull", modal(layout, 70, 10), true, true)
+	ui.app.SetFocus(form)
+}
+
+// pullModel downloads a model while showing its progress in a modal, which
+// Esc closes to cancel the download. It blocks until the download ends.
+func (ui *UI) pullModel(ctx context.Context, model string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	view := tview.NewTextView().SetDynamicColors(true)
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf("Pulling %s", tview.Escape(model))).
+		SetTitleAlign(tview.AlignLeft)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			cancel()
+			return nil
+		}
+		return event
+	})
+	fmt.Fprint(view, "\n  Connecting…")
+	ui.app.QueueUpdateDraw(func() {
+		ui.pages.RemovePage("pull")
+		ui.pages.AddPage("pull", modal(view, pullBarWidth+20, 10), true, true)
+		ui.app.SetFocus(view)
+	})
+	defer ui.app.QueueUpdateDraw(func() {
+		ui.closePull()
+	})
+
+	// Layers download one after another, so the total grows as they start
+	layers := make(map[string]chat.PullProgress)
+	var lastStatus string
+	var lastDraw time.Time
+	return ui.chat.Pull(ctx, model, func(progress chat.PullProgress) {
+		if progress.Digest != "" && progress.Total > 0 {
+			layers[progress.Digest] = progress
+		}
+		if progress.Status == lastStatus && time.Since(lastDraw) < pullRedrawInterval {
+			return
+		}
+		lastStatus, lastDraw = progress.Status, time.Now()
+
+		text := renderPullProgress(progress.Status, layers)
+		ui.app.QueueUpdateDraw(func() {
+			view.SetText(text)
+		})
+	})
+}
+
+// renderPullProgress shows the current step and a bar for all layers seen so far
+func renderPullProgress(status string, layers map[string]chat.PullProgress) string {
+	var total, completed int64
+	for _, layer := range layers {
+		total += layer.Total
+		completed += layer.Completed
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n  %s\n\n", tview.Escape(status))
+	if total > 0 {
+		filled := int(completed * pullBarWidth / total)
+		filled = min(filled, pullBarWidth)
+		fmt.Fprintf(&b, "  [success]%s[-]%s %3d%%\n  %s / %s\n",
+			strings.Repeat("█", filled),
+			strings.Repeat("░", pullBarWidth-filled),
+			completed*100/total,
+			attach.FormatSize(completed), attach.FormatSize(total))
+	} else {
+		b.WriteString("\n\n")
+	}
+	b.WriteString("\n  [muted]Esc cancels[-]")
+	return b.String()
+}
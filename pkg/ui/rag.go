@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/rag"
+
+	"github.com/rivo/tview"
+)
+
+// Number of chunks added to a prompt unless rag_top_k says otherwise
+const defaultRAGTopK = 4
+
+// How long embedding a prompt may delay sending it
+const ragQueryTimeout = 30 * time.Second
+
+func (ui *UI) ragCommand(args []string) {
+	if ui.rag == nil {
+		index, err := rag.Open()
+		if err != nil {
+			ui.commandError("RAG index: %v", err)
+			return
+		}
+		ui.rag = index
+	}
+
+	usage := "usage: /rag add <dir> | cancel | remove <dir> | list | clear"
+	if len(args) == 0 {
+		ui.commandOutput(usage)
+		return
+	}
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			ui.commandOutput(usage)
+			return
+		}
+		if ui.ragCancel != nil {
+			ui.commandOutput("Already indexing a directory; /rag cancel stops it")
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		ui.ragCancel = cancel
+		go ui.ragAdd(ctx, attach.ExpandHome(args[1]))
+	case "cancel":
+		if ui.ragCancel == nil {
+			ui.commandOutput("Not indexing anything")
+			return
+		}
+		ui.stopRAG()
+	case "remove", "rm":
+		if len(args) != 2 {
+			ui.commandOutput(usage)
+			return
+		}
+		removed, err := ui.rag.Remove(attach.ExpandHome(args[1]))
+		switch {
+		case err != nil:
+			ui.commandError("%v", err)
+		case !removed:
+			ui.commandOutput("%s is not indexed", args[1])
+		default:
+			ui.commandOutput("Removed %s", args[1])
+		}
+	case "list", "ls":
+		sources, counts := ui.rag.List()
+		if len(sources) == 0 {
+			ui.commandOutput("No directories indexed. Add one with /rag add <dir>")
+			return
+		}
+		for _, source := range sources {
+			ui.commandOutput("%s  %d files, %d chunks, added %s",
+				displayPath(source.Dir), len(source.Files), counts[source.Dir], source.Added.Format("2006-01-02 15:04"))
+		}
+	case "clear":
+		if err := ui.rag.Clear(); err != nil {
+			ui.commandError("%v", err)
+			return
+		}
+		ui.commandOutput("Cleared the RAG index")
+	default:
+		ui.commandOutput(usage)
+	}
+}
+
+// ragAdd embeds a directory in the background, showing progress in the
+// chat title, until it's done or ctx is cancelled
+func (ui *UI) ragAdd(ctx context.Context, dir string) {
+	model := ui.chat.EmbedModel()
+	embed := func(ctx context.Context, inputs []string) ([][]float32, error) {
+		return ui.chat.Embed(ctx, model, inputs)
+	}
+	progress := func(done, total int) {
+		ui.app.QueueUpdateDraw(func() {
+			ui.chatView.SetTitle(fmt.Sprintf("Chat · embedding %s %d/%d", filepath.Base(dir), done, total))
+		})
+	}
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.commandOutput("Indexing %s with %s…", displayPath(dir), model)
+	})
+	stats, err := ui.rag.Add(ctx, dir, model, embed, progress)
+	ui.app.QueueUpdateDraw(func() {
+		ui.ragCancel = nil
+		ui.chatView.SetTitle("Chat")
+		if errors.Is(err, context.Canceled) {
+			ui.commandOutput("Stopped indexing %s", displayPath(dir))
+			return
+		}
+		if err != nil {
+			ui.commandError("/rag add %s: %v", displayPath(dir), err)
+			return
+		}
+		ui.commandOutput("Indexed %d files into %d chunks (%d embedded, %d files skipped)",
+			stats.Files, stats.Chunks, stats.Embedded, stats.Skipped)
+	})
+}
+
+// stopRAG cancels the /rag add in progress, which reports when it has
+// stopped
+func (ui *UI) stopRAG() {
+	if ui.ragCancel != nil {
+		ui.ragCancel()
+	}
+}
+
+// retrieve finds the indexed chunks most similar to the prompt and hands
+// them to the chat for the next turn, which sends them alongside the prompt
+// without keeping them in the history. It runs on the sending goroutine
+// before the request is made.
+func (ui *UI) retrieve(index *rag.Index, prompt string) []rag.Hit {
+	if index == nil || index.Empty() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ragQueryTimeout)
+	defer cancel()
+	vectors, err := ui.chat.Embed(ctx, index.EmbedModel(), []string{prompt})
+	if err != nil {
+		ui.app.QueueUpdateDraw(func() {
+			fmt.Fprintf(ui.chatView, "[warning]Sending without RAG context: %s[-]\n", tview.Escape(err.Error()))
+		})
+		return nil
+	}
+
+	topK := defaultRAGTopK
+	if k := ui.chat.Config().RAGTopK; k > 0 {
+		topK = k
+	}
+	hits := index.Search(vectors[0], topK)
+	if len(hits) == 0 {
+		return nil
+	}
+
+	var excerpts strings.Builder
+	excerpts.WriteString("Excerpts from local files that may help with the user's latest question. Cite them by number, like [1], when you use them.\n\n")
+	for i, hit := range hits {
+		fmt.Fprintf(&excerpts, "[%d] %s:%d-%d\n```\n%s\n```\n\n", i+1, displayPath(hit.Path), hit.StartLine, hit.EndLine, hit.Text)
+	}
+	ui.chat.SetTurnContext(strings.TrimSpace(excerpts.String()))
+	return hits
+}
+
+// renderCitations lists the retrieved chunks under the answer
+func (ui *UI) renderCitations(hits []rag.Hit) {
+	if len(hits) == 0 {
+		return
+	}
+	citations := make([]string, len(hits))
+	for i, hit := range hits {
+		citations[i] = fmt.Sprintf("[%d] %s:%d-%d", i+1, displayPath(hit.Path), hit.StartLine, hit.EndLine)
+	}
+	fmt.Fprintf(ui.chatView, "[muted]Sources: %s[-]\n", tview.Escape(strings.Join(citations, " · ")))
+}
+
+// displayPath shortens a path relative to the working directory or home
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+			return "~/" + rest
+		}
+	}
+	return path
+}
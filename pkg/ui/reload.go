@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"llm_term/pkg/config"
+	"llm_term/pkg/theme"
+)
+
+// How often the config file is checked for changes
+const configPollInterval = time.Second
+
+// watchConfig reloads the config file whenever it changes. A file that
+// doesn't exist yet is picked up once it's created.
+func (ui *UI) watchConfig() {
+	path := ui.chat.Config().Path
+	last := stampFile(path)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		stamp := stampFile(path)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+		ui.reloadConfig()
+	}
+}
+
+// fileStamp changes whenever a file is written, replaced or removed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// reloadConfig applies the config file's current contents, keeping the
+// previous settings if they don't validate. The conversation is kept; a
+// response in progress finishes with the settings it started with.
+func (ui *UI) reloadConfig() {
+	cfg, err := ui.chat.Config().Reload()
+	ui.app.QueueUpdateDraw(func() {
+		if err == nil {
+			err = ui.applyConfig(cfg)
+		}
+		if err != nil {
+			ui.showToast("Config not reloaded: "+err.Error(), true)
+			return
+		}
+		name := filepath.Base(cfg.Path)
+		if cfg.ProfileName != "" {
+			name += ", profile " + cfg.ProfileName
+		}
+		ui.showToast("Reloaded "+name, false)
+	})
+}
+
+// applyConfig switches to new settings after a reload. Nothing changes if
+// they're rejected.
+func (ui *UI) applyConfig(cfg *config.Config) error {
+	keys, err := newKeymap(ui.actions(), cfg.Keybinds)
+	if err != nil {
+		return err
+	}
+	// A theme picked with /theme stays until the configured one changes
+	previous := ui.chat.Config()
+	if !sameTheme(previous, cfg) {
+		if err := ui.setTheme(cfg.Theme, cfg.Themes); err != nil {
+			return err
+		}
+	}
+	ui.keys = keys
+	// Likewise a strip toggled by hand stays as it is
+	if previous.CompactKeybinds != cfg.CompactKeybinds {
+		ui.setCompactKeybinds(cfg.CompactKeybinds)
+	}
+	ui.updateKeybindDisplay()
+
+	ui.chat.SetConfig(cfg)
+	if toolsChanged(previous, cfg) {
+		ui.registerTools(cfg)
+	}
+	// Show the new model now rather than with the next answer
+	ui.metrics.SetModelMetrics(cfg.Model, 0)
+	return nil
+}
+
+// sameTheme reports whether two configs give the same palette
+func sameTheme(a, b *config.Config) bool {
+	pa, errA := theme.Resolve(a.Theme, a.Themes)
+	pb, errB := theme.Resolve(b.Theme, b.Themes)
+	return errA == nil && errB == nil && pa == pb
+}
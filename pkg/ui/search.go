@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"llm_term/pkg/types"
+)
+
+// chatSearch is an active /pattern or ?pattern search over the chat view
+type chatSearch struct {
+	backward bool
+	matches  [][]int
+	current  int
+}
+
+// Tags wrapped around each match, with the theme's highlight color as the
+// background, and a pattern that takes them out again
+const (
+	matchColor = "highlight"
+	matchOpen  = `["search-%d"][:` + matchColor + `]`
+	matchClose = `[:-][""]`
+)
+
+var matchTagsPattern = regexp.MustCompile(`\["search-\d+"\]\[:` + matchColor + `\]((?s:.*?))\[:-\]\[""\]`)
+
+// prompt is a one-line question asked in the input field, e.g. a search pattern
+type prompt struct {
+	label string
+	title string
+	done  func(text string)
+	// back is the mode the prompt was asked from, returned to once it's done
+	back types.Mode
+}
+
+func (ui *UI) startPrompt(label, title string, done func(text string)) {
+	ui.prompt = &prompt{label: label, title: title, done: done, back: ui.currentMode}
+	ui.setMode(types.PromptMode)
+}
+
+// compileSearch uses smart case like vim: patterns without uppercase letters
+// match case-insensitively. Invalid regular expressions are searched literally.
+func compileSearch(pattern string) *regexp.Regexp {
+	flags := ""
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		flags = "(?i)"
+	}
+	re, err := regexp.Compile(flags + pattern)
+	if err != nil {
+		re = regexp.MustCompile(flags + regexp.QuoteMeta(pattern))
+	}
+	return re
+}
+
+// searchChat finds every match of pattern in the plain transcript, highlights
+// them and jumps to the first one in the search direction.
+func (ui *UI) searchChat(pattern string, backward bool) {
+	if pattern == "" {
+		return
+	}
+	ui.clearSearch()
+
+	base := ui.chatView.GetText(false)
+	plain, offsets := plainText(base)
+	matches := searchMatches(compileSearch(pattern), base, plain, offsets)
+
+	ui.search = &chatSearch{backward: backward, matches: matches}
+	if len(matches) == 0 {
+		return
+	}
+	if backward {
+		ui.search.current = len(matches) - 1
+	}
+
+	spans := make([]span, len(matches))
+	for i, m := range matches {
+		spans[i] = span{
+			start: m[0],
+			end:   m[1],
+			open:  fmt.Sprintf(matchOpen, i),
+			close: matchClose,
+		}
+	}
+
+	ui.autoScroll = false
+	ui.chatView.SetText(insertTags(base, offsets, spans))
+	ui.showMatch()
+}
+
+// searchMatches finds the matches in the plain text of tagged that can be
+// highlighted. Empty matches are left out, and matches in the same escaped
+// tag are joined since insertTags highlights it whole.
+func searchMatches(re *regexp.Regexp, tagged, plain string, offsets []int) [][]int {
+	var matches [][]int
+	prevEnd := 0
+	for _, m := range re.FindAllStringIndex(plain, -1) {
+		if m[1] == m[0] {
+			continue
+		}
+		start, end := taggedRange(tagged, offsets, m[0], m[1])
+		if n := len(matches); n > 0 && start < prevEnd {
+			matches[n-1][1] = max(matches[n-1][1], m[1])
+			prevEnd = max(prevEnd, end)
+			continue
+		}
+		matches = append(matches, m)
+		prevEnd = end
+	}
+	return matches
+}
+
+// nextMatch moves to the next match in the search direction, or the
+// opposite direction if reverse is set (n and N).
+func (ui *UI) nextMatch(reverse bool) {
+	if ui.search == nil || len(ui.search.matches) == 0 {
+		return
+	}
+	step := 1
+	if ui.search.backward != reverse {
+		step = -1
+	}
+	n := len(ui.search.matches)
+	ui.search.current = (ui.search.current + step + n) % n
+	ui.showMatch()
+}
+
+func (ui *UI) showMatch() {
+	ui.autoScroll = false
+	ui.chatView.Highlight(fmt.Sprintf("search-%d", ui.search.current)).ScrollToHighlight()
+}
+
+// clearSearch removes search highlights, keeping the scroll position and
+// anything written to the chat since the search
+func (ui *UI) clearSearch() {
+	if ui.search == nil {
+		return
+	}
+	if len(ui.search.matches) > 0 {
+		row, col := ui.chatView.GetScrollOffset()
+		ui.chatView.Highlight()
+		ui.chatView.SetText(removeMatchTags(ui.chatView.GetText(false)))
+		ui.chatView.ScrollTo(row, col)
+	}
+	ui.search = nil
+}
+
+// removeMatchTags takes the search highlights out of the chat buffer
+func removeMatchTags(text string) string {
+	return matchTagsPattern.ReplaceAllString(text, "$1")
+}
+
+// searchStatus is shown next to the mode, e.g. "match 3/12"
+func (ui *UI) searchStatus() string {
+	if ui.search == nil {
+		return ""
+	}
+	if len(ui.search.matches) == 0 {
+		return "no matches"
+	}
+	return fmt.Sprintf("match %d/%d", ui.search.current+1, len(ui.search.matches))
+}
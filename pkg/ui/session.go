@@ -0,0 +1,247 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"llm_term/pkg/attach"
+	"llm_term/pkg/session"
+	"llm_term/pkg/theme"
+	"llm_term/pkg/types"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// LoadSession resumes a saved conversation: the model sees the previous
+// messages and the chat view shows the transcript.
+func (ui *UI) LoadSession(sess *session.Session) {
+	ui.openSession(sess, -1)
+}
+
+// openSession loads a session and, if hit is a message index, scrolls to
+// that message and highlights its label.
+func (ui *UI) openSession(sess *session.Session, hit int) {
+	ui.session = sess
+	ui.chat.SetHistory(sess.Messages)
+
+	ui.chatView.Clear()
+	ui.codeBlocks = nil
+	ui.folds = nil
+	ui.selectedFold = -1
+	calls := make(map[string]types.ToolCall)
+	for i, message := range sess.Messages {
+		if message.Role == "tool" {
+			call, ok := calls[message.ToolCallID]
+			if !ok {
+				call = types.ToolCall{Function: types.ToolCallFunction{Name: message.ToolName}}
+			}
+			ui.addFold(toolSummary(call, nil), message.Content, false)
+			continue
+		}
+		for _, call := range message.ToolCalls {
+			calls[call.ID] = call
+		}
+		ui.renderMessage(i, message)
+	}
+
+	if hit >= 0 {
+		ui.autoScroll = false
+		ui.chatView.Highlight(messageRegion(hit)).ScrollToHighlight()
+		return
+	}
+	ui.autoScroll = true
+	ui.chatView.ScrollToEnd()
+}
+
+func messageRegion(index int) string {
+	return fmt.Sprintf("msg-%d", index)
+}
+
+// renderMessage prints a message from history the same way it looked live.
+// The label is a region so search results can scroll to it.
+func (ui *UI) renderMessage(index int, message types.Message) {
+	switch message.Role {
+	case "user":
+		// Attached files show as chips, as they did when the prompt was sent
+		text, attachments := attach.Collapse(message.Content)
+		fmt.Fprintf(ui.chatView, "[user][\"%s\"]You:[\"\"][-] %s\n", messageRegion(index), text)
+		if len(attachments) > 0 {
+			fmt.Fprintf(ui.chatView, "%s\n", attachmentChips(attachments))
+		}
+		if len(message.Images) > 0 {
+			fmt.Fprintf(ui.chatView, "  [::r] 🖼 %d image(s) [::-]\n", len(message.Images))
+		}
+	case "assistant":
+		if message.Content == "" && message.Thinking == "" && len(message.ToolCalls) > 0 {
+			return
+		}
+		if message.Thinking != "" {
+			fmt.Fprintf(ui.chatView, "[assistant][\"%s\"]AI:[\"\"][-] ", messageRegion(index))
+			ui.addThinkingFold(message.Thinking)
+			fmt.Fprintf(ui.chatView, "%s\n", message.Content)
+			ui.renderCodeBlockMarkers(message.Content)
+			return
+		}
+		fmt.Fprintf(ui.chatView, "[assistant][\"%s\"]AI:[\"\"][-] %s\n", messageRegion(index), message.Content)
+		ui.renderCodeBlockMarkers(message.Content)
+	}
+}
+
+// showSessionSearch opens an overlay that searches all saved sessions.
+// Queries wrapped in slashes (/pattern/) are treated as regular expressions.
+func (ui *UI) showSessionSearch() {
+	if ui.store == nil {
+		fmt.Fprintf(ui.chatView, "[error]Session search unavailable: no session directory[-]\n")
+		return
+	}
+
+	input := tview.NewInputField().
+		SetLabel("Search: ").
+		SetFieldWidth(0).
+		SetFieldBackgroundColor(theme.Color("background"))
+	list := tview.NewList().
+		SetHighlightFullLine(true).
+		SetSecondaryTextColor(theme.Color("muted")).
+		SetSelectedStyle(theme.Selected())
+	status := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[muted]Enter: search  Tab: results  Esc: close  /regex/[-]")
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false).
+		AddItem(status, 1, 0, false)
+	layout.SetBorder(true).
+		SetTitle("Search sessions").
+		SetTitleAlign(tview.AlignLeft)
+
+	closeSearch := func() {
+		ui.pages.RemovePage("search")
+		ui.updateModeState()
+	}
+
+	var hits []session.Result
+	runSearch := func() {
+		query := strings.TrimSpace(input.GetText())
+		if query == "" {
+			return
+		}
+		opts := session.SearchOptions{}
+		if len(query) > 1 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+			opts.Regex = true
+			query = query[1 : len(query)-1]
+		}
+
+		list.Clear()
+		var err error
+		hits, err = ui.store.Search(query, opts)
+		if err != nil {
+			status.SetText(fmt.Sprintf("[error]%v[-]", err))
+			return
+		}
+		for _, hit := range hits {
+			list.AddItem(
+				fmt.Sprintf("%s [muted]%s[-]", tview.Escape(hit.Title), hit.Updated.Format("2006-01-02 15:04")),
+				highlightSnippet(hit),
+				0, nil)
+		}
+		status.SetText(fmt.Sprintf("[muted]%d matches  Enter: open  Tab: edit query  Esc: close[-]", len(hits)))
+		if len(hits) > 0 {
+			ui.app.SetFocus(list)
+		}
+	}
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			runSearch()
+		case tcell.KeyEscape:
+			closeSearch()
+		case tcell.KeyTab:
+			if list.GetItemCount() > 0 {
+				ui.app.SetFocus(list)
+			}
+		}
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			closeSearch()
+			return nil
+		case tcell.KeyTab:
+			ui.app.SetFocus(input)
+			return nil
+		}
+		return event
+	})
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		hit := hits[index]
+		sess, err := ui.store.Load(hit.SessionID)
+		if err != nil {
+			status.SetText(fmt.Sprintf("[error]%v[-]", err))
+			return
+		}
+		closeSearch()
+		ui.openSession(sess, hit.Message)
+	})
+
+	ui.pages.AddPage("search", modal(layout, 100, 30), true, true)
+	ui.app.SetFocus(input)
+}
+
+// highlightSnippet escapes a result snippet and colors the hits
+func highlightSnippet(hit session.Result) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range hit.Matches {
+		b.WriteString(tview.Escape(hit.Snippet[last:match[0]]))
+		b.WriteString("[match]")
+		b.WriteString(tview.Escape(hit.Snippet[match[0]:match[1]]))
+		b.WriteString("[muted]")
+		last = match[1]
+	}
+	b.WriteString(tview.Escape(hit.Snippet[last:]))
+	return b.String()
+}
+
+// saveSession persists the current conversation after each exchange. It's
+// called when a response completes, so the session is updated and saved on
+// the UI goroutine, where the sidebar renames and pins it too.
+func (ui *UI) saveSession() {
+	if ui.store == nil {
+		return
+	}
+	ui.app.QueueUpdateDraw(func() {
+		history := ui.chat.History()
+		if len(history) == 0 {
+			return
+		}
+
+		now := time.Now()
+		created := ui.session == nil
+		if created {
+			ui.session = &session.Session{
+				ID:      session.NewID(),
+				Title:   session.DefaultTitle(history),
+				Created: now,
+			}
+		}
+		ui.session.Messages = history
+		ui.session.Updated = now
+		if ui.currentModel != "" {
+			ui.session.Model = ui.currentModel
+		}
+
+		if err := ui.store.Save(ui.session); err != nil {
+			fmt.Fprintf(ui.chatView, "[error]Failed to save session: %v[-]\n", err)
+			return
+		}
+		if created {
+			go ui.generateTitle(ui.session.ID, ui.session.Title, history)
+		}
+		ui.refreshSidebar("")
+	})
+}
@@ -0,0 +1,253 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"llm_term/pkg/session"
+	"llm_term/pkg/theme"
+	"llm_term/pkg/types"
+
+	"github.com/rivo/tview"
+)
+
+// Width of the session sidebar, including its border
+const sidebarWidth = 32
+
+// How long the model gets to come up with a title for a new session
+const titleTimeout = 30 * time.Second
+
+func (ui *UI) setupSidebar() {
+	ui.sidebar = tview.NewList().
+		SetHighlightFullLine(true).
+		SetSelectedFocusOnly(true).
+		SetSecondaryTextColor(theme.Color("muted")).
+		SetSelectedStyle(theme.Selected())
+	ui.sidebar.SetBorder(true).
+		SetTitle("Sessions").
+		SetTitleAlign(tview.AlignLeft)
+	// Clicking a session opens it like Enter does
+	ui.sidebar.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		ui.openSelectedSession()
+	})
+}
+
+// arrangeContent lays out the row holding the chat, with the sidebar to its
+// left when it's shown
+func (ui *UI) arrangeContent() {
+	ui.content.Clear()
+	if ui.sidebarOpen {
+		ui.content.AddItem(ui.sidebar, sidebarWidth, 0, false)
+	}
+	// Chat view with weight 4, and the metrics at a fixed width
+	ui.content.AddItem(ui.chatView, 0, 4, false)
+	ui.content.AddItem(ui.metricsView, 26, 0, false)
+}
+
+// showSidebar opens the sidebar if it's hidden and moves into it
+func (ui *UI) showSidebar() {
+	if ui.store == nil {
+		fmt.Fprintf(ui.chatView, "[error]Session sidebar unavailable: no session directory[-]\n")
+		return
+	}
+	if !ui.sidebarOpen {
+		ui.sidebarOpen = true
+		ui.arrangeContent()
+		current := ""
+		if ui.session != nil {
+			current = ui.session.ID
+		}
+		ui.refreshSidebar(current)
+	}
+	ui.setMode(types.SidebarMode)
+}
+
+func (ui *UI) hideSidebar() {
+	ui.sidebarOpen = false
+	ui.arrangeContent()
+	ui.setMode(types.NormalMode)
+}
+
+// refreshSidebar lists the saved sessions again, pinned ones first, and
+// selects the one with the given id, or else keeps the selection
+func (ui *UI) refreshSidebar(selectID string) {
+	if !ui.sidebarOpen {
+		return
+	}
+	if selectID == "" {
+		if sess := ui.selectedSession(); sess != nil {
+			selectID = sess.ID
+		}
+	}
+	sessions, err := ui.store.List()
+	if err != nil {
+		ui.showToast("Listing sessions failed: "+err.Error(), true)
+		return
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Pinned && !sessions[j].Pinned
+	})
+
+	ui.sidebarSessions = sessions
+	ui.sidebar.Clear()
+	selected := 0
+	for i, sess := range sessions {
+		title := tview.Escape(sess.Title)
+		if ui.session != nil && sess.ID == ui.session.ID {
+			title = "[::b]" + title + "[::-]"
+		}
+		if sess.Pinned {
+			title = "[mode]★[-] " + title
+		}
+		details := sess.Updated.Format("2006-01-02 15:04")
+		if sess.Model != "" {
+			details = sess.Model + " · " + details
+		}
+		ui.sidebar.AddItem(title, tview.Escape(details), 0, nil)
+		if sess.ID == selectID {
+			selected = i
+		}
+	}
+	if len(sessions) == 0 {
+		ui.sidebar.AddItem("[muted]No saved sessions[-]", "", 0, nil)
+	}
+	ui.sidebar.SetCurrentItem(selected)
+}
+
+// selectedSession is the session under the cursor in the sidebar
+func (ui *UI) selectedSession() *session.Session {
+	index := ui.sidebar.GetCurrentItem()
+	if index < 0 || index >= len(ui.sidebarSessions) {
+		return nil
+	}
+	sess := ui.sidebarSessions[index]
+	// The session being chatted in is the copy that's saved after each
+	// exchange, so changes go to it
+	if ui.session != nil && ui.session.ID == sess.ID {
+		return ui.session
+	}
+	return sess
+}
+
+func (ui *UI) moveSidebar(step int) {
+	if count := len(ui.sidebarSessions); count > 0 {
+		ui.sidebar.SetCurrentItem(min(max(ui.sidebar.GetCurrentItem()+step, 0), count-1))
+	}
+}
+
+func (ui *UI) openSelectedSession() {
+	sess := ui.selectedSession()
+	if sess == nil || ui.isAIResponding {
+		return
+	}
+	if sess != ui.session {
+		// Load it again in case it changed since it was listed
+		loaded, err := ui.store.Load(sess.ID)
+		if err != nil {
+			ui.showToast(err.Error(), true)
+			return
+		}
+		ui.openSession(loaded, -1)
+	}
+	ui.refreshSidebar(sess.ID)
+	ui.setMode(types.NormalMode)
+}
+
+// newSession clears the chat for a new conversation, which is saved as a
+// new session after its first exchange
+func (ui *UI) newSession() {
+	ui.openSession(&session.Session{}, -1)
+	ui.session = nil
+	ui.refreshSidebar("")
+}
+
+func (ui *UI) renameSession() {
+	sess := ui.selectedSession()
+	if sess == nil {
+		return
+	}
+	ui.startPrompt("Title: ", "RENAME", func(text string) {
+		title := strings.Join(strings.Fields(text), " ")
+		if title == "" {
+			return
+		}
+		sess.Title = title
+		ui.saveFromSidebar(sess)
+	})
+	ui.inputField.SetText(sess.Title)
+}
+
+func (ui *UI) deleteSession() {
+	sess := ui.selectedSession()
+	if sess == nil {
+		return
+	}
+	current := sess == ui.session
+	if current && ui.isAIResponding {
+		ui.flash = "Wait for the response to finish"
+		return
+	}
+	ui.startPrompt(fmt.Sprintf("Delete %q? (y/N) ", sess.Title), "DELETE", func(text string) {
+		if answer := strings.ToLower(strings.TrimSpace(text)); answer != "y" && answer != "yes" {
+			return
+		}
+		if err := ui.store.Delete(sess.ID); err != nil {
+			ui.showToast("Deleting the session failed: "+err.Error(), true)
+			return
+		}
+		if current {
+			ui.newSession()
+			return
+		}
+		ui.refreshSidebar("")
+	})
+}
+
+func (ui *UI) togglePin() {
+	if sess := ui.selectedSession(); sess != nil {
+		sess.Pinned = !sess.Pinned
+		ui.saveFromSidebar(sess)
+	}
+}
+
+func (ui *UI) saveFromSidebar(sess *session.Session) {
+	if err := ui.store.Save(sess); err != nil {
+		ui.showToast("Saving the session failed: "+err.Error(), true)
+		return
+	}
+	ui.refreshSidebar(sess.ID)
+}
+
+// generateTitle asks the model to title a new session in place of its first
+// message. A title given by hand in the meantime is kept.
+func (ui *UI) generateTitle(id, placeholder string, history []types.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	title, err := ui.chat.Title(ctx, history)
+	if err != nil {
+		// The first message makes a fine title too
+		return
+	}
+
+	ui.app.QueueUpdateDraw(func() {
+		sess := ui.session
+		if sess == nil || sess.ID != id {
+			loaded, err := ui.store.Load(id)
+			if err != nil {
+				return
+			}
+			sess = loaded
+		}
+		if sess.Title != placeholder {
+			return
+		}
+		sess.Title = title
+		if err := ui.store.Save(sess); err != nil {
+			return
+		}
+		ui.refreshSidebar("")
+	})
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"strings"
+
+	"llm_term/pkg/theme"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// themeCommand lists the themes, or switches to one for the rest of the
+// session
+func (ui *UI) themeCommand(args []string) {
+	cfg := ui.chat.Config()
+	if len(args) == 0 {
+		names := theme.Names(cfg.Themes)
+		for i, name := range names {
+			if name == ui.themeName {
+				names[i] = name + " (current)"
+			}
+		}
+		ui.commandOutput("Themes: %s", strings.Join(names, ", "))
+		return
+	}
+	if theme.NoColor() {
+		ui.commandError("NO_COLOR is set, so themes don't apply")
+		return
+	}
+	if err := ui.setTheme(args[0], cfg.Themes); err != nil {
+		ui.commandError("%v", err)
+		return
+	}
+	ui.commandOutput("Switched to the %s theme", args[0])
+}
+
+// setTheme applies a palette to every view, including text already written
+func (ui *UI) setTheme(name string, custom map[string]theme.Palette) error {
+	palette, err := theme.Resolve(name, custom)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = theme.Default
+	}
+	ui.themeName = name
+	theme.Apply(palette)
+	ui.recolor(ui.pages)
+	// At startup the views are yet to be created, and take the new colors
+	if ui.chatView == nil {
+		return nil
+	}
+	if ui.layout != nil {
+		ui.recolor(ui.layout)
+	}
+	// The sidebar isn't part of the layout while it's hidden
+	ui.recolor(ui.sidebar)
+	ui.recolorInput()
+
+	// Text views keep the colors they parsed, so have them parse again
+	row, col := ui.chatView.GetScrollOffset()
+	ui.chatView.SetText(ui.chatView.GetText(false))
+	ui.chatView.ScrollTo(row, col)
+	ui.updateKeybindDisplay()
+	return nil
+}
+
+// box is what every view has in common
+type box interface {
+	SetBackgroundColor(color tcell.Color) *tview.Box
+	SetBorderColor(color tcell.Color) *tview.Box
+	SetTitleColor(color tcell.Color) *tview.Box
+}
+
+// recolor sets the theme's colors on a view and the views inside it.
+// Overlays aren't included since they're created with the current colors.
+func (ui *UI) recolor(p tview.Primitive) {
+	if b, ok := p.(box); ok {
+		b.SetBackgroundColor(theme.Color("background"))
+		b.SetBorderColor(theme.Color("border"))
+		b.SetTitleColor(theme.Color("title"))
+	}
+	switch p := p.(type) {
+	case *tview.TextView:
+		p.SetTextColor(theme.Color("text"))
+	case *tview.List:
+		p.SetMainTextColor(theme.Color("text")).
+			SetSecondaryTextColor(theme.Color("muted")).
+			SetSelectedStyle(theme.Selected())
+	case *tview.Flex:
+		for i := 0; i < p.GetItemCount(); i++ {
+			if item := p.GetItem(i); item != nil {
+				ui.recolor(item)
+			}
+		}
+	}
+}
+
+func (ui *UI) recolorInput() {
+	background := theme.Color("background")
+	ui.inputField.SetBackgroundColor(background)
+	ui.inputField.SetFieldBackgroundColor(background)
+	ui.inputField.SetFieldTextColor(theme.Color("text"))
+	ui.inputField.SetLabelColor(theme.Color("mode"))
+	ui.inputField.SetPlaceholderStyle(tcell.StyleDefault.Background(background).Foreground(theme.Color("text")))
+}
@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// thinkingStream is the reasoning fold currently being streamed
+type thinkingStream struct {
+	fold  *fold
+	start time.Time
+	// written is the exact text added to the buffer so far, so it can be
+	// swapped for the collapsed fold when the answer starts
+	written string
+}
+
+// streamThinking appends reasoning to an open fold, starting one on the
+// first chunk. It must run on the UI goroutine.
+func (ui *UI) streamThinking(text string) {
+	if ui.thinking == nil {
+		f := &fold{
+			id:       fmt.Sprintf("fold-%d", len(ui.folds)),
+			summary:  "Thinking…",
+			open:     true,
+			thinking: true,
+		}
+		ui.folds = append(ui.folds, f)
+		if current := ui.chatView.GetText(false); current != "" && !strings.HasSuffix(current, "\n") {
+			fmt.Fprint(ui.chatView, "\n")
+		}
+		ui.thinking = &thinkingStream{fold: f, start: time.Now()}
+		ui.writeThinking(fmt.Sprintf(`["%s"][muted]▾ %s`, f.id, f.summary))
+	}
+
+	f := ui.thinking.fold
+	if f.body == "" {
+		text = strings.TrimLeft(text, "\n")
+		if text == "" {
+			return
+		}
+		ui.writeThinking("\n    ")
+	}
+	f.body += text
+	ui.writeThinking(strings.ReplaceAll(tview.Escape(text), "\n", "\n    "))
+}
+
+func (ui *UI) writeThinking(text string) {
+	ui.thinking.written += text
+	fmt.Fprint(ui.chatView, text)
+}
+
+// finishThinking replaces the streamed reasoning with a fold that is
+// collapsed unless thinking is shown
+func (ui *UI) finishThinking() {
+	stream := ui.thinking
+	if stream == nil {
+		return
+	}
+	ui.thinking = nil
+
+	f := stream.fold
+	f.summary = fmt.Sprintf("Thought for %s", time.Since(stream.start).Round(time.Second))
+	if time.Since(stream.start) < time.Second {
+		f.summary = "Thought for a moment"
+	}
+	f.open = ui.showThinking
+
+	text := ui.chatView.GetText(false)
+	start := strings.Index(text, fmt.Sprintf(`["%s"]`, f.id))
+	// Leave the text as it is if something else was printed in between
+	if start < 0 || !strings.HasPrefix(text[start:], stream.written) {
+		fmt.Fprint(ui.chatView, `[-][""]`+"\n")
+		return
+	}
+	end := start + len(stream.written)
+	ui.chatView.SetText(text[:start] + f.render() + "\n" + text[end:])
+}
+
+// addThinkingFold shows the reasoning of a message from history
+func (ui *UI) addThinkingFold(thinking string) {
+	f := ui.addFold("Thought", strings.TrimSpace(thinking), ui.showThinking)
+	f.thinking = true
+}
+
+// toggleThinking shows or hides the reasoning of every answer
+func (ui *UI) toggleThinking() {
+	ui.showThinking = !ui.showThinking
+	for _, f := range ui.folds {
+		if f.thinking && f.open != ui.showThinking {
+			ui.toggleFold(f)
+		}
+	}
+	ui.chatView.Highlight()
+	if ui.showThinking {
+		ui.flash = "thinking shown"
+	} else {
+		ui.flash = "thinking hidden"
+	}
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"time"
+
+	"llm_term/pkg/theme"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// How long toasts stay up. Errors stay longer since they need reading.
+const (
+	toastDuration      = 3 * time.Second
+	errorToastDuration = 8 * time.Second
+)
+
+// Maximum width of a toast, including its padding
+const toastWidth = 60
+
+// toast is a short notice drawn over the top-right corner of the chat. It
+// isn't a page, so it never takes focus from what the user is doing.
+type toast struct {
+	text    string
+	isError bool
+	until   time.Time
+}
+
+// showToast puts up a notice. It must be called from the UI goroutine; the
+// periodic redraw takes it down once it expires.
+func (ui *UI) showToast(text string, isError bool) {
+	duration := toastDuration
+	if isError {
+		duration = errorToastDuration
+	}
+	ui.toast = &toast{text: text, isError: isError, until: time.Now().Add(duration)}
+}
+
+// drawToast is the application's after-draw function
+func (ui *UI) drawToast(screen tcell.Screen) {
+	t := ui.toast
+	if t == nil {
+		return
+	}
+	if time.Now().After(t.until) {
+		ui.toast = nil
+		return
+	}
+
+	x, y, width, _ := ui.chatView.GetRect()
+	boxWidth := min(toastWidth, width-2)
+	if boxWidth < 10 {
+		return
+	}
+	lines := tview.WordWrap(t.text, boxWidth-2)
+	left := x + width - boxWidth - 1
+
+	background := theme.Color("success")
+	if t.isError {
+		background = theme.Color("error")
+	}
+	foreground := theme.Color("background")
+	style := tcell.StyleDefault.Background(background).Foreground(foreground)
+	// Without colors the box is shown in reverse video
+	attributes := ""
+	if theme.NoColor() {
+		style = style.Reverse(true)
+		attributes = "[::r]"
+	}
+	for row := 0; row < len(lines)+2; row++ {
+		for col := 0; col < boxWidth; col++ {
+			screen.SetContent(left+col, y+1+row, ' ', nil, style)
+		}
+	}
+	// Print keeps the background just filled in
+	for i, line := range lines {
+		tview.Print(screen, attributes+tview.Escape(line), left+1, y+2+i, boxWidth-2, tview.AlignLeft, foreground)
+	}
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+
+	"llm_term/pkg/chat"
+	"llm_term/pkg/config"
+	"llm_term/pkg/tools"
+
+	"github.com/rivo/tview"
+)
+
+// registerTools enables the built-in tools listed in the config's tools,
+// replacing any registered before
+func (ui *UI) registerTools(cfg *config.Config) {
+	for _, name := range ui.builtinTools {
+		ui.chat.UnregisterTool(name)
+	}
+	ui.builtinTools = nil
+	register := func(tool chat.Tool) {
+		ui.chat.RegisterTool(tool)
+		ui.builtinTools = append(ui.builtinTools, tool.Name)
+	}
+
+	if slices.Contains(cfg.Tools, "shell") {
+		register(tools.NewShell(ui.shellPolicy, ui.confirm).Tool())
+	}
+	if slices.Contains(cfg.Tools, "fs") {
+		workspace, err := tools.NewWorkspace(cfg.Workspace, ui.confirm)
+		if err != nil {
+			fmt.Fprintf(ui.chatView, "[error]Filesystem tools disabled: %v[-]\n", tview.Escape(err.Error()))
+			return
+		}
+		for _, tool := range workspace.Tools() {
+			register(tool)
+		}
+	}
+}
+
+// toolsChanged reports whether a reload enables other built-in tools or
+// moves the workspace
+func toolsChanged(a, b *config.Config) bool {
+	return !slices.Equal(a.Tools, b.Tools) || a.Workspace != b.Workspace
+}
+
+// shellPolicy is the run_shell policy from the current config
+func (ui *UI) shellPolicy() config.Shell {
+	return ui.chat.Config().Shell
+}
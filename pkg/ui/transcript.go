@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Patterns for the tview tags that may appear in the chat buffer, anchored so
+// they can be matched at the current position while scanning.
+var (
+	styleTagPattern   = regexp.MustCompile(`^\[([a-zA-Z]+|#[0-9a-fA-F]{6}|-)?(:([a-zA-Z]+|#[0-9a-fA-F]{6}|-)?(:([bdilrsuBDILRSU]+|-)?(:[^\]]*)?)?)?\]`)
+	regionTagPattern  = regexp.MustCompile(`^\["[a-zA-Z0-9_,;: \-\.]*"\]`)
+	escapedTagPattern = regexp.MustCompile(`^\[[^\[\]]+\[+\]`)
+)
+
+// plainText strips tview tags from tagged text. offsets maps every byte of
+// the plain text to its position in tagged, with one extra entry for the end,
+// so positions found in the plain text can be mapped back.
+func plainText(tagged string) (plain string, offsets []int) {
+	var b strings.Builder
+	offsets = make([]int, 0, len(tagged)+1)
+
+	for i := 0; i < len(tagged); {
+		if tagged[i] == '[' {
+			rest := tagged[i:]
+			// Escaped tags such as "[red[]" print as "[red]"
+			if m := escapedTagPattern.FindString(rest); m != "" {
+				for j := 0; j < len(m)-2; j++ {
+					b.WriteByte(m[j])
+					offsets = append(offsets, i+j)
+				}
+				b.WriteByte(']')
+				offsets = append(offsets, i+len(m)-1)
+				i += len(m)
+				continue
+			}
+			if m := regionTagPattern.FindString(rest); m != "" {
+				i += len(m)
+				continue
+			}
+			if m := styleTagPattern.FindString(rest); len(m) > 2 {
+				i += len(m)
+				continue
+			}
+		}
+		b.WriteByte(tagged[i])
+		offsets = append(offsets, i)
+		i++
+	}
+	offsets = append(offsets, len(tagged))
+	return b.String(), offsets
+}
+
+// span marks a range of the plain text to wrap in extra tags
+type span struct {
+	start, end int
+	open       string
+	close      string
+}
+
+// insertTags wraps the given plain text ranges of tagged in their open and
+// close tags. Spans must be sorted and must not overlap. Empty spans are
+// skipped, and spans that end up in the same escaped tag are wrapped as one.
+func insertTags(tagged string, offsets []int, spans []span) string {
+	var ranges []span
+	for _, s := range spans {
+		if s.end <= s.start {
+			continue
+		}
+		start, end := taggedRange(tagged, offsets, s.start, s.end)
+		if n := len(ranges); n > 0 && start < ranges[n-1].end {
+			ranges[n-1].end = max(ranges[n-1].end, end)
+			continue
+		}
+		ranges = append(ranges, span{start: start, end: end, open: s.open, close: s.close})
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		b.WriteString(tagged[last:r.start])
+		b.WriteString(r.open)
+		b.WriteString(tagged[r.start:r.end])
+		b.WriteString(r.close)
+		last = r.end
+	}
+	b.WriteString(tagged[last:])
+	return b.String()
+}
+
+// taggedRange maps a non-empty range of the plain text to the bytes of
+// tagged it covers
+func taggedRange(tagged string, offsets []int, start, end int) (from, to int) {
+	from = offsets[start]
+	// Close right after the last byte of the range so following tags stay
+	// outside of it
+	to = offsets[end-1] + 1
+	// Tags inside an escaped tag such as "[red[]" would break it up, so a
+	// range that starts or ends in one takes all of it
+	if tagStart, _, ok := escapedTagAround(tagged, from); ok {
+		from = tagStart
+	}
+	if _, tagEnd, ok := escapedTagAround(tagged, to-1); ok {
+		to = tagEnd
+	}
+	return from, to
+}
+
+// escapedTagAround finds the escaped tag, such as "[red[]", that the byte
+// at pos is part of
+func escapedTagAround(tagged string, pos int) (start, end int, ok bool) {
+	i := pos
+	if tagged[i] == ']' {
+		i--
+		for i >= 0 && tagged[i] == '[' {
+			i--
+		}
+	}
+	for i >= 0 && tagged[i] != '[' && tagged[i] != ']' {
+		i--
+	}
+	if i < 0 || tagged[i] != '[' {
+		return 0, 0, false
+	}
+	m := escapedTagPattern.FindString(tagged[i:])
+	if m == "" || i+len(m) <= pos {
+		return 0, 0, false
+	}
+	return i, i + len(m), true
+}
@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		tagged string
+		plain  string
+	}{
+		{"plain", "plain"},
+		{"[user]You:[-] hi", "You: hi"},
+		{"[::b]bold[::-] and [#ff0000:black:u]red[-:-:-]", "bold and red"},
+		{`["search-1"][:highlight]hit[:-][""]`, "hit"},
+		{"[red[] stays", "[red] stays"},
+		{"[[] and []", "[[] and []"},
+		{"a [1] b", "a [1] b"},
+		{"[code]  [2[] · yc[-]", "  [2] · yc"},
+	}
+	for _, test := range tests {
+		plain, offsets := plainText(test.tagged)
+		if plain != test.plain {
+			t.Errorf("plainText(%q) = %q, want %q", test.tagged, plain, test.plain)
+			continue
+		}
+		if len(offsets) != len(plain)+1 || offsets[len(plain)] != len(test.tagged) {
+			t.Errorf("plainText(%q) offsets = %v", test.tagged, offsets)
+			continue
+		}
+		// Every plain byte maps back to the same character
+		for i := 0; i < len(plain); i++ {
+			if c := test.tagged[offsets[i]]; c != plain[i] {
+				t.Errorf("plainText(%q): byte %d maps to %q, want %q", test.tagged, i, c, plain[i])
+			}
+		}
+	}
+}
+
+func TestInsertTags(t *testing.T) {
+	tests := []struct {
+		tagged string
+		query  string
+		want   string
+	}{
+		{"hello world", "world", "hello <world>"},
+		{"[user]You:[-] hi you", "you", "[user]<You>:[-] hi <you>"},
+		{"[::b]ab[::-]cd", "bc", "[::b]a<b[::-]c>d"},
+		{"[red[] red", "red", "<[red[]> <red>"},
+		{"see [12[] here", "2]", "see <[12[]> here"},
+		{"[::b][1[][::-]", "1", "[::b]<[1[]>[::-]"},
+		// Several matches in one escaped tag wrap it once
+		{`x ["a","b"[] y`, `"`, `x <["a","b"[]> y`},
+		{`["a","b"[] ["c"[]`, `"`, `<["a","b"[]> <["c"[]>`},
+		{"nothing here", "zzz", "nothing here"},
+	}
+	for _, test := range tests {
+		plain, offsets := plainText(test.tagged)
+		var spans []span
+		lower, query := strings.ToLower(plain), strings.ToLower(test.query)
+		for start := 0; ; {
+			i := strings.Index(lower[start:], query)
+			if i < 0 {
+				break
+			}
+			spans = append(spans, span{start: start + i, end: start + i + len(query), open: "<", close: ">"})
+			start += i + len(query)
+		}
+		if got := insertTags(test.tagged, offsets, spans); got != test.want {
+			t.Errorf("insertTags(%q, %q) = %q, want %q", test.tagged, test.query, got, test.want)
+		}
+	}
+}
+
+func TestInsertTagsSkipsEmptySpans(t *testing.T) {
+	text := "[user]You:[-] hi"
+	_, offsets := plainText(text)
+	spans := []span{{start: 2, end: 2, open: "<", close: ">"}, {start: 5, end: 7, open: "<", close: ">"}}
+	if got, want := insertTags(text, offsets, spans), "[user]You:[-] <hi>"; got != want {
+		t.Errorf("insertTags = %q, want %q", got, want)
+	}
+}
+
+func TestSearchMatches(t *testing.T) {
+	tests := []struct {
+		tagged  string
+		pattern string
+		want    string
+	}{
+		{"a b a", "a", "[[0 1] [4 5]]"},
+		// Empty matches can't be highlighted so they aren't counted
+		{"abc", "x*", "[]"},
+		{"abc", "b?", "[[1 2]]"},
+		// Matches in one escaped tag are one match
+		{`["a","b"[]`, `"`, "[[1 8]]"},
+		{`["a","b"[] "`, `"`, "[[1 8] [10 11]]"},
+	}
+	for _, test := range tests {
+		plain, offsets := plainText(test.tagged)
+		matches := searchMatches(compileSearch(test.pattern), test.tagged, plain, offsets)
+		if got := fmt.Sprint(matches); got != test.want {
+			t.Errorf("searchMatches(%q, %q) = %s, want %s", test.tagged, test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestRemoveMatchTags(t *testing.T) {
+	text := "[user]You:[-] find me\n"
+	plain, offsets := plainText(text)
+	start := strings.Index(plain, "me")
+	marked := insertTags(text, offsets, []span{{start: start, end: start + 2, open: fmt.Sprintf(matchOpen, 1), close: matchClose}})
+	if marked == text {
+		t.Fatal("nothing was marked")
+	}
+	// Output written after the search must survive clearing it
+	marked += "AI: later\n"
+	if got, want := removeMatchTags(marked), text+"AI: later\n"; got != want {
+		t.Errorf("removeMatchTags = %q, want %q", got, want)
+	}
+}
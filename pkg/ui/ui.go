@@ -1,12 +1,20 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"llm_term/pkg/attach"
 	"llm_term/pkg/chat"
+	"llm_term/pkg/config"
+	"llm_term/pkg/mcp"
+	"llm_term/pkg/rag"
+	"llm_term/pkg/session"
 	"llm_term/pkg/system"
+	"llm_term/pkg/theme"
 	"llm_term/pkg/types"
 
 	"github.com/gdamore/tcell/v2"
@@ -15,12 +23,25 @@ import (
 
 type UI struct {
 	app         *tview.Application
+	pages       *tview.Pages
 	chatView    *tview.TextView
 	inputField  *tview.InputField
 	keybindView *tview.TextView
 	metricsView *tview.TextView
 	currentMode types.Mode
-	modeKeybinds map[types.Mode][]types.KeyBinding
+	keys        *keymap
+	themeName   string
+	// layout is the main page, recolored when the theme changes
+	layout      *tview.Flex
+	// frame holds the chat, input line and keybind strip
+	frame       *tview.Flex
+	compactKeybinds bool
+	// content is the row with the sidebar, chat and metrics
+	content     *tview.Flex
+	sidebar     *tview.List
+	sidebarOpen bool
+	// sidebarSessions are the sessions listed in the sidebar, in order
+	sidebarSessions []*session.Session
 	isAIResponding bool
 	spinnerFrames []string
 	currentSpinnerFrame int
@@ -29,50 +50,99 @@ type UI struct {
 	autoScroll  bool
 	metrics     *system.Metrics
 	currentModel string
+	store       *session.Store
+	session     *session.Session
+	prompt      *prompt
+	search      *chatSearch
+	visual      *visualSelection
+	codeBlocks  []codeBlock
+	folds       []*fold
+	selectedFold int
+	thinking    *thinkingStream
+	// showThinking expands reasoning folds instead of collapsing them
+	showThinking bool
+	// flash is a one-off message shown in the mode indicator until the next key
+	flash       string
+	// enter is the Enter press left to the input field, kept so its
+	// modifiers reach the keymap when the field hands it back
+	enter       *tcell.EventKey
+	// builtinTools are the names of the built-in tools registered from the
+	// config, replaced when it's reloaded
+	builtinTools []string
+	toast       *toast
+	commands    []slashCommand
+	rag         *rag.Index
+	// ragCancel stops the /rag add in progress, if there is one
+	ragCancel   context.CancelFunc
+	// citations are the chunks retrieved for the response in progress
+	citations   []rag.Hit
+	mcpMu       sync.Mutex
+	mcpClients  []*mcp.Client
+	mcpClosed   bool
 }
 
-func New() *UI {
+func New(cfg *config.Config) (*UI, error) {
 	ui := &UI{
 		app:         tview.NewApplication(),
+		pages:       tview.NewPages(),
 		currentMode: types.InputMode,
 		isAIResponding: false,
 		spinnerFrames: []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
 		currentSpinnerFrame: 0,
 		stopSpinner: make(chan bool, 1),
-		chat:        chat.New(),
+		chat:        chat.New(cfg),
 		autoScroll:  true,
-		metrics:     system.New(),
+		metrics:     system.New(cfg.Model),
+		selectedFold: -1,
+		compactKeybinds: cfg.CompactKeybinds,
+	}
+	keys, err := newKeymap(ui.actions(), cfg.Keybinds)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Path, err)
+	}
+	ui.keys = keys
+	// Views take their colors from the theme as they're created
+	if err := ui.setTheme(cfg.Theme, cfg.Themes); err != nil {
+		return nil, err
 	}
 
-	ui.modeKeybinds = map[types.Mode][]types.KeyBinding{
-		types.NormalMode: {
-			{Key: "q", Description: "quit"},
-			{Key: "i", Description: "enter input mode"},
-			{Key: "j", Description: "scroll down"},
-			{Key: "k", Description: "scroll up"},
-			{Key: "gg", Description: "scroll to top"},
-			{Key: "G", Description: "scroll to bottom"},
-			{Key: "Ctrl+D", Description: "scroll down half page"},
-			{Key: "Ctrl+U", Description: "scroll up half page"},
-		},
-		types.InputMode: {
-			{Key: "Esc", Description: "enter normal mode"},
-			{Key: "Enter", Description: "send message"},
-		},
-		types.ResponseMode: {
-			{Key: "q", Description: "quit"},
-			{Key: "j", Description: "scroll down"},
-			{Key: "k", Description: "scroll up"},
-			{Key: "gg", Description: "scroll to top"},
-			{Key: "G", Description: "scroll to bottom"},
-			{Key: "Ctrl+D", Description: "scroll down half page"},
-			{Key: "Ctrl+U", Description: "scroll up half page"},
-		},
+	// Sessions are optional; without a store conversations just aren't saved
+	if store, err := session.NewStore(); err == nil {
+		ui.store = store
+	}
+	if index, err := rag.Open(); err == nil {
+		ui.rag = index
 	}
 
 	ui.setupViews()
+	ui.setupSidebar()
 	ui.setupHandlers()
-	return ui
+	ui.setupAttachments()
+	ui.setupCommands()
+	ui.registerTools(ui.chat.Config())
+	ui.startMCP()
+
+	// Show each tool call and the model's reasoning as collapsible blocks,
+	// and offer to pull a model the server is missing
+	ui.chat.SetHooks(chat.Hooks{
+		OnToolCall: func(call types.ToolCall, result string, err error) {
+			ui.app.QueueUpdateDraw(func() {
+				ui.addToolFold(call, result, err)
+			})
+		},
+		OnThinking: func(text string) {
+			ui.app.QueueUpdateDraw(func() {
+				ui.streamThinking(text)
+			})
+		},
+		OnThinkingDone: func() {
+			ui.app.QueueUpdateDraw(func() {
+				ui.finishThinking()
+			})
+		},
+		OnModelMissing: ui.offerPull,
+	})
+	return ui, nil
 }
 
 func (ui *UI) setupViews() {
@@ -80,6 +150,7 @@ func (ui *UI) setupViews() {
 	ui.chatView = tview.NewTextView()
 	ui.chatView.
 		SetDynamicColors(true).
+		SetRegions(true).
 		SetScrollable(true).
 		SetWordWrap(true)
 	ui.chatView.SetBorder(true).
@@ -107,11 +178,9 @@ func (ui *UI) setupViews() {
 	ui.inputField = tview.NewInputField().
 		SetLabel("> ").
 		SetFieldWidth(0).
-		SetFieldBackgroundColor(tcell.ColorDefault).
-		SetFieldTextColor(tcell.ColorDefault).
-		SetPlaceholderStyle(tcell.StyleDefault.Background(tcell.ColorDefault).Foreground(tcell.ColorDefault)).
 		SetPlaceholder(" ") // Use space as placeholder to prevent cursor
 	ui.inputField.SetBorder(false)
+	ui.recolorInput()
 
 	// Create metrics view
 	ui.metricsView = tview.NewTextView().
@@ -128,104 +197,16 @@ func (ui *UI) setupViews() {
 }
 
 func (ui *UI) setupHandlers() {
-	lastKeyTime := time.Now()
-	lastKey := ' '
-
-	handleScrollCommand := func(event *tcell.EventKey) *tcell.EventKey {
-		// Handle Ctrl+D and Ctrl+U
-		if event.Key() == tcell.KeyCtrlD {
-			_, _, _, height := ui.chatView.GetInnerRect()
-			row, _ := ui.chatView.GetScrollOffset()
-			ui.chatView.ScrollTo(row+height/2, 0)
-			ui.autoScroll = false
-			return nil
-		} else if event.Key() == tcell.KeyCtrlU {
-			_, _, _, height := ui.chatView.GetInnerRect()
-			row, _ := ui.chatView.GetScrollOffset()
-			newRow := row - height/2
-			if newRow < 0 {
-				newRow = 0
-			}
-			ui.chatView.ScrollTo(newRow, 0)
-			ui.autoScroll = false
-			return nil
-		}
-
-		switch event.Rune() {
-		case 'g':
-			// Check if this is a double 'g' within 500ms
-			if lastKey == 'g' && time.Since(lastKeyTime) < 500*time.Millisecond {
-				ui.chatView.ScrollToBeginning()
-				ui.autoScroll = false
-				lastKey = ' ' // Reset last key
-				return nil
-			}
-			lastKey = 'g'
-			lastKeyTime = time.Now()
-			return nil
-		case 'G':
-			ui.chatView.ScrollToEnd()
-			ui.autoScroll = true
-			return nil
-		case 'j': // Scroll down
-			row, _ := ui.chatView.GetScrollOffset()
-			ui.chatView.ScrollTo(row+1, 0)
-			ui.autoScroll = false
-			return nil
-		case 'k': // Scroll up
-			row, _ := ui.chatView.GetScrollOffset()
-			if row > 0 {
-				ui.chatView.ScrollTo(row-1, 0)
-				ui.autoScroll = false
-			}
-			return nil
-		case 'q':
-			ui.app.Stop()
-			return nil
-		}
-		lastKey = event.Rune()
-		lastKeyTime = time.Now()
-		return event
-	}
-
-	// Handle input
+	// Enter is left to the input field so it can pick a completion first;
+	// otherwise the field hands it back here
 	ui.inputField.SetDoneFunc(func(key tcell.Key) {
-		if key == tcell.KeyEnter && ui.currentMode == types.InputMode {
-			// Block new messages while AI is responding
-			if ui.isAIResponding {
-				return
-			}
-			
-			text := ui.inputField.GetText()
-			if text == "" {
-				return
+		if key == tcell.KeyEnter {
+			event := ui.enter
+			if event == nil {
+				event = tcell.NewEventKey(key, 0, tcell.ModNone)
 			}
-			
-			ui.autoScroll = true // Reset auto-scroll when sending message
-			fmt.Fprintf(ui.chatView, "[yellow]You:[white] %s\n", text)
-			ui.inputField.SetText("")
-			ui.chatView.ScrollToEnd()
-			
-			// Set responding flag and update UI
-			ui.isAIResponding = true
-			ui.setMode(types.ResponseMode)
-			ui.startSpinner()
-			
-			// Call the streaming chat function with response handler
-			go ui.chat.StreamChat(text, ui.chatView, ui.app, 
-				func(response types.ChatResponse) {
-					ui.updatePerformanceMetrics(response)
-					// Ensure we keep scrolling during response if auto-scroll is enabled
-					if ui.autoScroll {
-						ui.app.QueueUpdateDraw(func() {
-							ui.chatView.ScrollToEnd()
-						})
-					}
-				},
-				func() {
-					ui.handleResponseComplete()
-				},
-			)
+			ui.enter = nil
+			ui.keys.dispatch(ui.currentMode, event)
 		}
 	})
 
@@ -246,52 +227,128 @@ func (ui *UI) setupHandlers() {
 
 	// Global key handler
 	ui.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// Handle Ctrl+C globally
-		if event.Key() == tcell.KeyCtrlC {
-			if ui.isAIResponding {
-				// Cancel AI response
-				ui.chat.Cancel()
-				return nil
-			}
-			// In other modes, ignore Ctrl+C
+		event = ui.handleKey(event)
+		// tview quits on Ctrl+C, which is for cancelling here
+		if event != nil && event.Key() == tcell.KeyCtrlC {
 			return nil
 		}
+		return event
+	})
+}
 
-		switch ui.currentMode {
-		case types.ResponseMode:
-			return handleScrollCommand(event)
-		case types.NormalMode:
-			if event.Rune() == 'i' {
-				ui.setMode(types.InputMode)
-				ui.autoScroll = true // Reset auto-scroll when entering input mode
-				return nil
-			}
-			return handleScrollCommand(event)
-		case types.InputMode:
-			if event.Key() == tcell.KeyEscape {
-				ui.setMode(types.NormalMode)
-				return nil
-			}
+// handleKey runs the action bound to a key in the current mode, and passes
+// on keys that aren't bound
+func (ui *UI) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// Overlays handle their own keys, but a response can still be cancelled
+	// behind one, e.g. while a tool call waits for approval
+	if ui.overlayOpen() {
+		if ui.isAIResponding && ui.keys.matches(types.ResponseMode, "cancel", event) {
+			ui.chat.Cancel()
+			return nil
 		}
 		return event
-	})
+	}
+	ui.flash = ""
+
+	// Text modes leave Enter to the input field's done func
+	textMode := ui.currentMode == types.InputMode || ui.currentMode == types.PromptMode
+	if textMode && event.Key() == tcell.KeyEnter {
+		ui.enter = event
+		return event
+	}
+	if ui.keys.dispatch(ui.currentMode, event) {
+		return nil
+	}
+	return event
+}
+
+// send sends the input field as a prompt, or runs it as a slash command
+func (ui *UI) send() {
+	// Block new messages while AI is responding
+	if ui.isAIResponding {
+		return
+	}
+	
+	text := ui.inputField.GetText()
+	if text == "" {
+		return
+	}
+	if ui.runSlashCommand(text) {
+		return
+	}
+	
+	// Inline @path references; history gets the file contents while
+	// the chat view only shows a chip per file
+	expanded, attachments := attach.Expand(text)
+
+	ui.autoScroll = true // Reset auto-scroll when sending message
+	ui.chatView.Highlight() // Clear any search hit
+	fmt.Fprintf(ui.chatView, "[user]You:[-] %s\n", text)
+	if len(attachments) > 0 {
+		fmt.Fprintf(ui.chatView, "%s\n", attachmentChips(attachments))
+	}
+	ui.inputField.SetText("")
+	ui.chatView.ScrollToEnd()
+	
+	// Set responding flag and update UI
+	ui.isAIResponding = true
+	ui.setMode(types.ResponseMode)
+	ui.startSpinner()
+	
+	// Call the streaming chat function with response handler
+	message := types.Message{Content: expanded, Images: attach.Images(attachments)}
+	index := ui.rag
+	go func() {
+		ui.citations = ui.retrieve(index, text)
+		ui.chat.StreamChat(message, ui.chatView, ui.app, 
+			func(response types.ChatResponse) {
+				ui.updatePerformanceMetrics(response)
+				// Ensure we keep scrolling during response if auto-scroll is enabled
+				if ui.autoScroll {
+					ui.app.QueueUpdateDraw(func() {
+						ui.chatView.ScrollToEnd()
+					})
+				}
+			},
+			func() {
+				ui.handleResponseComplete()
+			},
+		)
+	}()
+}
 
-	// Add keybind for Ctrl+C to response mode
-	ui.modeKeybinds[types.ResponseMode] = append(ui.modeKeybinds[types.ResponseMode],
-		types.KeyBinding{Key: "Ctrl+C", Description: "cancel response"})
+// confirmPrompt hands the prompt's answer to whoever asked
+func (ui *UI) confirmPrompt() {
+	p := ui.prompt
+	if p == nil {
+		return
+	}
+	text := ui.inputField.GetText()
+	ui.prompt = nil
+	ui.setMode(p.back)
+	p.done(text)
 }
 
 func (ui *UI) updateModeState() {
-	if ui.currentMode == types.NormalMode || ui.currentMode == types.ResponseMode {
-		ui.inputField.SetBackgroundColor(tcell.ColorDefault)
-		ui.inputField.SetFieldBackgroundColor(tcell.ColorDefault)
+	if ui.currentMode == types.PromptMode && ui.prompt != nil {
+		ui.inputField.SetLabel(ui.prompt.label)
+		ui.inputField.SetDisabled(false)
+		ui.app.SetFocus(ui.inputField)
+		ui.inputField.SetText("")
+		return
+	}
+	ui.inputField.SetLabel("> ")
+
+	if ui.currentMode != types.InputMode {
+		ui.inputField.SetBackgroundColor(theme.Color("background"))
+		ui.inputField.SetFieldBackgroundColor(theme.Color("background"))
 		ui.inputField.SetDisabled(true) // Disable input when not in input mode
 		// Hide cursor and label in non-input modes
 		ui.inputField.SetText(" ") // Set space to prevent cursor
-		ui.app.SetFocus(nil)
+		ui.restoreFocus()
 	} else {
-		ui.inputField.SetBackgroundColor(tcell.ColorDefault)
-		ui.inputField.SetFieldBackgroundColor(tcell.ColorDefault)
+		ui.inputField.SetBackgroundColor(theme.Color("background"))
+		ui.inputField.SetFieldBackgroundColor(theme.Color("background"))
 		ui.inputField.SetDisabled(false) // Enable input in input mode
 		ui.app.SetFocus(ui.inputField)
 		// Show cursor and label in input mode
@@ -300,7 +357,15 @@ func (ui *UI) updateModeState() {
 }
 
 func (ui *UI) setMode(mode types.Mode) {
+	// Search highlights only live in normal mode and its prompts
+	if mode != types.NormalMode && mode != types.PromptMode {
+		ui.clearSearch()
+	}
+	if mode != types.VisualMode {
+		ui.stopVisual()
+	}
 	ui.currentMode = mode
+	ui.keys.reset()
 	ui.updateModeState()
 	ui.updateKeybindDisplay()
 }
@@ -309,7 +374,11 @@ func (ui *UI) updateKeybindDisplay() {
 	ui.keybindView.Clear()
 	
 	// Display keybinds for current mode in a grid
-	binds := ui.modeKeybinds[ui.currentMode]
+	binds := append(ui.keys.help(ui.currentMode), keyHints[ui.currentMode]...)
+	if ui.compactKeybinds {
+		fmt.Fprint(ui.keybindView, statusLine(binds))
+		return
+	}
 	bindsPerRow := 2  // Reduce to 2 bindings per row for better visibility
 	
 	// First pass: calculate max widths for alignment
@@ -332,7 +401,7 @@ func (ui *UI) updateKeybindDisplay() {
 			keyPadding := strings.Repeat(" ", maxKeyWidth-len(bind.Key))
 			descPadding := strings.Repeat(" ", maxDescWidth-len(bind.Description))
 			
-			fmt.Fprintf(ui.keybindView, "[green]%s%s[white]:%s%s", 
+			fmt.Fprintf(ui.keybindView, "[key]%s%s[-]:%s%s", 
 				bind.Key, 
 				keyPadding,
 				bind.Description,
@@ -349,13 +418,31 @@ func (ui *UI) updateKeybindDisplay() {
 }
 
 func (ui *UI) getModeText() string {
+	if ui.flash != "" {
+		return fmt.Sprintf("[mode]%s[-]", ui.flash)
+	}
 	switch ui.currentMode {
 	case types.ResponseMode:
-		return fmt.Sprintf("[yellow]AI responding %s[white]", ui.spinnerFrames[ui.currentSpinnerFrame])
+		return fmt.Sprintf("[mode]AI responding %s[-]", ui.spinnerFrames[ui.currentSpinnerFrame])
 	case types.NormalMode:
-		return "[yellow]NORMAL MODE[white]"
+		if status := ui.searchStatus(); status != "" {
+			return fmt.Sprintf("[mode]NORMAL %s[-]", status)
+		}
+		return "[mode]NORMAL MODE[-]"
+	case types.PromptMode:
+		if p := ui.prompt; p != nil {
+			return fmt.Sprintf("[mode]%s[-]", p.title)
+		}
+		return "[mode]NORMAL MODE[-]"
+	case types.SidebarMode:
+		return "[mode]SESSIONS[-]"
+	case types.VisualMode:
+		if v := ui.visual; v != nil && v.messages {
+			return "[mode]VISUAL MESSAGE[-]"
+		}
+		return "[mode]VISUAL[-]"
 	default:
-		return "[yellow]INPUT MODE[white]"
+		return "[mode]INPUT MODE[-]"
 	}
 }
 
@@ -363,20 +450,19 @@ func (ui *UI) Run() error {
 	// Start system metrics collection
 	ui.metrics.Start()
 	defer ui.metrics.Stop()
+	defer ui.stopMCP()
+	defer ui.stopRAG()
+	go ui.watchConfig()
 
 	// Create main flex container for layout
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow)
 
-	// Create horizontal flex for chat and metrics
+	// Create horizontal flex for the sidebar, chat and metrics
 	contentFlex := tview.NewFlex().
 		SetDirection(tview.FlexColumn)
-
-	// Add chat view with weight 4
-	contentFlex.AddItem(ui.chatView, 0, 4, false)
-
-	// Add metrics view with fixed width
-	contentFlex.AddItem(ui.metricsView, 26, 0, false)
+	ui.content = contentFlex
+	ui.arrangeContent()
 
 	// Create a flex for input area that matches chat width
 	inputAreaFlex := tview.NewFlex().
@@ -399,7 +485,9 @@ func (ui *UI) Run() error {
 	flex.AddItem(inputAreaFlex, 1, 0, true)
 
 	// Add keybind display with fixed height
-	flex.AddItem(ui.keybindView, 6, 0, false)  // Fixed height of 6 lines
+	// Fixed height of 6 lines, or a single line when compact
+	flex.AddItem(ui.keybindView, ui.keybindHeight(), 0, false)
+	ui.frame = flex
 
 	// Update mode text and metrics periodically
 	go func() {
@@ -414,7 +502,7 @@ func (ui *UI) Run() error {
 				fmt.Fprintf(ui.metricsView, "%s", ui.metrics.GetFormattedMetrics(0))
 
 				// Ensure input field maintains focus in input mode
-				if ui.currentMode == types.InputMode {
+				if ui.currentMode == types.InputMode && !ui.overlayOpen() {
 					ui.app.SetFocus(ui.inputField)
 				}
 			})
@@ -438,7 +526,44 @@ func (ui *UI) Run() error {
 	// Initial setup
 	ui.updateKeybindDisplay()
 
-	return ui.app.SetRoot(centered, true).EnableMouse(true).Run()
+	// Overlays such as the session search are added as pages on top
+	ui.layout = centered
+	ui.pages.AddPage("main", centered, true, true)
+
+	ui.app.SetAfterDrawFunc(ui.drawToast)
+
+	return ui.app.SetRoot(ui.pages, true).EnableMouse(true).Run()
+}
+
+// modal centers an overlay of at most the given size over the chat
+func modal(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// restoreFocus gives the keyboard back to the current mode's view once an
+// overlay closes
+func (ui *UI) restoreFocus() {
+	switch ui.currentMode {
+	case types.InputMode:
+		ui.app.SetFocus(ui.inputField)
+	case types.SidebarMode:
+		ui.app.SetFocus(ui.sidebar)
+	default:
+		ui.app.SetFocus(nil)
+	}
+}
+
+// overlayOpen reports whether a modal page is shown on top of the chat
+func (ui *UI) overlayOpen() bool {
+	name, _ := ui.pages.GetFrontPage()
+	return name != "" && name != "main"
 }
 
 func (ui *UI) startSpinner() {
@@ -483,6 +608,8 @@ func (ui *UI) updatePerformanceMetrics(response types.ChatResponse) {
 }
 
 func (ui *UI) handleResponseComplete() {
+	ui.saveSession()
+
 	// Send stop signal to spinner without blocking
 	select {
 	case ui.stopSpinner <- true:
@@ -491,6 +618,11 @@ func (ui *UI) handleResponseComplete() {
 	}
 
 	ui.app.QueueUpdateDraw(func() {
+		if reply, ok := ui.chat.LastReply(); ok {
+			ui.renderCodeBlockMarkers(reply.Content)
+			ui.renderCitations(ui.citations)
+		}
+		ui.citations = nil
 		ui.isAIResponding = false
 		ui.setMode(types.InputMode)
 		ui.autoScroll = true
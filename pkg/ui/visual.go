@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"llm_term/pkg/clipboard"
+	"llm_term/pkg/types"
+)
+
+// visualSelection is a vim-style selection of transcript lines. In message
+// mode (V) it grows to cover whole messages.
+type visualSelection struct {
+	messages bool
+	anchor   int
+	cursor   int
+	// base is the chat buffer without the selection highlight
+	base    string
+	plain   string
+	offsets []int
+	// lineStarts holds the plain text offset of every line
+	lineStarts []int
+}
+
+// Prefixes of the lines that start a message in the transcript
+var messageLabels = []string{"You: ", "AI: "}
+
+func (ui *UI) startVisual(messages bool) {
+	ui.clearSearch()
+	base := ui.chatView.GetText(false)
+	plain, offsets := plainText(base)
+	if strings.TrimSpace(plain) == "" {
+		return
+	}
+
+	v := &visualSelection{messages: messages, base: base, plain: plain, offsets: offsets}
+	v.lineStarts = []int{0}
+	for i := 0; i < len(plain); i++ {
+		if plain[i] == '\n' && i+1 < len(plain) {
+			v.lineStarts = append(v.lineStarts, i+1)
+		}
+	}
+
+	// Start on the last non-empty line, where the latest answer ends
+	v.cursor = len(v.lineStarts) - 1
+	for v.cursor > 0 && strings.TrimSpace(v.line(v.cursor)) == "" {
+		v.cursor--
+	}
+	v.anchor = v.cursor
+
+	ui.setMode(types.VisualMode)
+	ui.visual = v
+	ui.autoScroll = false
+	ui.renderVisual()
+}
+
+func (v *visualSelection) line(i int) string {
+	end := len(v.plain)
+	if i+1 < len(v.lineStarts) {
+		end = v.lineStarts[i+1]
+	}
+	return strings.TrimSuffix(v.plain[v.lineStarts[i]:end], "\n")
+}
+
+func (v *visualSelection) isLabel(i int) bool {
+	line := v.line(i)
+	for _, label := range messageLabels {
+		if strings.HasPrefix(line, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the first and last selected line
+func (v *visualSelection) bounds() (int, int) {
+	from, to := v.anchor, v.cursor
+	if from > to {
+		from, to = to, from
+	}
+	if v.messages {
+		for from > 0 && !v.isLabel(from) {
+			from--
+		}
+		for to+1 < len(v.lineStarts) && !v.isLabel(to+1) {
+			to++
+		}
+	}
+	return from, to
+}
+
+// text returns the selected plain text. A single selected message is
+// copied without its "You:"/"AI:" label.
+func (v *visualSelection) text() string {
+	from, to := v.bounds()
+	lines := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		lines = append(lines, v.line(i))
+	}
+	if v.messages && v.isLabel(from) {
+		isSingle := true
+		for i := from + 1; i <= to; i++ {
+			if v.isLabel(i) {
+				isSingle = false
+				break
+			}
+		}
+		if isSingle {
+			for _, label := range messageLabels {
+				lines[0] = strings.TrimPrefix(lines[0], label)
+			}
+		}
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func (ui *UI) renderVisual() {
+	v := ui.visual
+	from, to := v.bounds()
+	end := len(v.plain)
+	if to+1 < len(v.lineStarts) {
+		end = v.lineStarts[to+1] - 1
+	}
+
+	selected := insertTags(v.base, v.offsets, []span{{
+		start: v.lineStarts[from],
+		end:   end,
+		open:  `["visual"]`,
+		close: `[""]`,
+	}})
+	ui.chatView.SetText(selected)
+	ui.chatView.Highlight("visual").ScrollToHighlight()
+}
+
+// stopVisual restores the chat buffer, keeping the scroll position
+func (ui *UI) stopVisual() {
+	if ui.visual == nil {
+		return
+	}
+	row, col := ui.chatView.GetScrollOffset()
+	ui.chatView.Highlight()
+	ui.chatView.SetText(ui.visual.base)
+	ui.chatView.ScrollTo(row, col)
+	ui.visual = nil
+}
+
+func (ui *UI) yankVisual() {
+	text := ui.visual.text()
+	lines := strings.Count(text, "\n") + 1
+	ui.setMode(types.NormalMode)
+
+	if err := clipboard.Copy(text); err != nil {
+		ui.flash = fmt.Sprintf("[error]%v", err)
+		return
+	}
+	ui.flash = fmt.Sprintf("yanked %d lines", lines)
+}
+
+// moveVisual moves the selection's free end by step lines, stopping at the
+// first and last line
+func (ui *UI) moveVisual(step int) {
+	v := ui.visual
+	v.cursor = min(max(v.cursor+step, 0), len(v.lineStarts)-1)
+	ui.renderVisual()
+}
+
+// switchVisual switches between selecting lines and messages, or leaves
+// visual mode when it's already selecting that way
+func (ui *UI) switchVisual(messages bool) {
+	if ui.visual.messages == messages {
+		ui.setMode(types.NormalMode)
+		return
+	}
+	ui.visual.messages = messages
+	ui.renderVisual()
+}